@@ -29,11 +29,26 @@ app.use('/api', router);
 app.listen(port);
 console.log('listening on port ' + port);
 
+// the same page size and cap the go handler uses
+const defaultLimit = 100
+const maxLimit = 1000
+
+const animalColumns = 'shelter_id, id, animal_name, animal_type, breed, color, sex, animal_size, date_of_birth'
+
 function getAnimals(req, res) {
-    let limit = req.query.limit
-    let query = `select * from animals limit ${limit}`
+    let limit = req.query.limit === undefined ? defaultLimit : Number(req.query.limit)
+    if (!Number.isInteger(limit) || limit < 1 || limit > maxLimit) {
+        res.status(400)
+        res.json({ error: 'limit must be between 1 and ' + maxLimit })
+        return
+    }
+    let shelter = req.query.shelter
+    let where = shelter
+        ? 'where deleted_at is null and shelter_id = $<shelter>'
+        : 'where deleted_at is null'
+    let query = `select ${animalColumns} from animals ${where} order by shelter_id, id limit $<limit>`
    
-    db.many(query)
+    db.any(query, { shelter, limit })
     .then((data) => {
         res.status(200)
         res.json({ animals: data });
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bbrombacher/animals/repository"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+)
+
+type GetAnimalsParams struct {
+	Limit int `json:"limit" schema:"limit"`
+	// Cursor is the next_cursor of the previous page, which this page
+	// starts after.
+	Cursor  string `json:"cursor" schema:"cursor"`
+	Format  string `json:"format" schema:"format"`
+	Shelter string `json:"shelter" schema:"shelter"`
+}
+
+func (p *GetAnimalsParams) setDefaults() {
+	if p.Format == "" {
+		p.Format = formatJSON
+	}
+}
+
+// validate also resolves the limit default, which depends on the format:
+// JSON pages default to defaultLimit, streamed exports to every row.
+func (p *GetAnimalsParams) validate() ParamErrors {
+	errs := ParamErrors{}
+	switch p.Format {
+	case formatJSON:
+		if p.Limit == 0 {
+			p.Limit = defaultLimit
+		}
+		checkRange(errs, "limit", p.Limit, 1, maxLimit)
+	case formatCSV, formatNDJSON:
+		if p.Limit < 0 {
+			errs["limit"] = "must not be negative"
+		}
+	default:
+		errs["format"] = "must be one of json, csv or ndjson"
+	}
+	if _, err := p.after(); err != nil {
+		errs["cursor"] = "must be a next_cursor from an earlier page"
+	}
+	return errs
+}
+
+// after returns the key the page starts after, nil for the first page.
+func (p GetAnimalsParams) after() (*repository.AnimalKey, error) {
+	if p.Cursor == "" {
+		return nil, nil
+	}
+	keys, err := decodeCursor(p.Cursor, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.AnimalKey{ShelterID: keys[0], ID: keys[1]}, nil
+}
+
+// GetAnimalParams are the query parameters of a single animal. Shelter is
+// needed when more than one shelter has an animal with the id.
+type GetAnimalParams struct {
+	Shelter string `json:"shelter" schema:"shelter"`
+}
+
+func (p *GetAnimalParams) setDefaults() {}
+
+func (p *GetAnimalParams) validate() ParamErrors {
+	return ParamErrors{}
+}
+
+var (
+	decoder = newDecoder()
+)
+
+func newDecoder() *schema.Decoder {
+	d := schema.NewDecoder()
+	d.IgnoreUnknownKeys(true)
+	return d
+}
+
+type AnimalController struct {
+	Animals repository.AnimalRepository
+	History repository.HistoryRepository
+	Cache   *ResponseCache
+}
+
+// query translates the list parameters, once validated, for the repository.
+func (p GetAnimalsParams) query(ctx context.Context) repository.AnimalQuery {
+	after, _ := p.after()
+	return repository.AnimalQuery{
+		ShelterID:     p.Shelter,
+		AdoptableOnly: adoptableOnly(ctx),
+		After:         after,
+		Limit:         p.Limit,
+	}
+}
+
+func (a AnimalController) GetAnimals(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	params := GetAnimalsParams{Format: negotiateFormat(req)}
+	if err := parseQuery(req, &params); err != nil {
+		writeParamErrors(w, err)
+		return
+	}
+
+	if params.Format != formatJSON {
+		a.exportAnimals(w, req, params)
+		return
+	}
+
+	cacheKey := a.Cache.Key(req, params)
+	if cached, ok := a.Cache.Get(cacheKey); ok {
+		a.Cache.writeCached(w, req, cached)
+		return
+	}
+
+	// one more row than the page shows whether another follows
+	query := params.query(ctx)
+	query.Limit++
+	result, err := a.Animals.List(ctx, query)
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, "failed to get data", err)
+		return
+	}
+
+	body := map[string]interface{}{}
+	if len(result) > params.Limit {
+		result = result[:params.Limit]
+		body["next_cursor"] = animalCursor(result[len(result)-1])
+	}
+	body["animals"] = result
+	resp, err := newCachedResponse(body)
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, "failed to encode response", err)
+		return
+	}
+	a.Cache.Add(cacheKey, resp)
+	a.Cache.writeCached(w, req, resp)
+}
+
+func (a AnimalController) GetAnimal(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	id := mux.Vars(req)["id"]
+
+	params := GetAnimalParams{}
+	if err := parseQuery(req, &params); err != nil {
+		writeParamErrors(w, err)
+		return
+	}
+
+	cacheKey := a.Cache.Key(req, []string{params.Shelter, id})
+	if cached, ok := a.Cache.Get(cacheKey); ok {
+		a.Cache.writeCached(w, req, cached)
+		return
+	}
+
+	result, err := a.Animals.Get(ctx, params.Shelter, id, adoptableOnly(ctx))
+	if errors.Is(err, repository.ErrNotFound) {
+		writeNotFound(w, "animal not found")
+		return
+	}
+	if errors.Is(err, repository.ErrAmbiguous) {
+		writeError(w, req, http.StatusConflict, "pass shelter, the animal id", err)
+		return
+	}
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, "failed to get data", err)
+		return
+	}
+
+	resp, err := newCachedResponse(map[string]interface{}{
+		"animal": result,
+	})
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, "failed to encode response", err)
+		return
+	}
+	a.Cache.Add(cacheKey, resp)
+	a.Cache.writeCached(w, req, resp)
+}
+
+// GetAnimalHistory lists the audited changes to an animal and its intakes,
+// including soft deleted ones. It is staff only, and never cached.
+func (a AnimalController) GetAnimalHistory(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	id := mux.Vars(req)["id"]
+
+	params := GetAnimalParams{}
+	if err := parseQuery(req, &params); err != nil {
+		writeParamErrors(w, err)
+		return
+	}
+
+	// deleted animals keep their history, so this looks them up too
+	animals, err := a.Animals.List(ctx, repository.AnimalQuery{ShelterID: params.Shelter, IDs: []string{id}, IncludeDeleted: true, Limit: 2})
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, "failed to get data", err)
+		return
+	}
+	switch len(animals) {
+	case 0:
+		writeNotFound(w, "animal not found")
+		return
+	case 2:
+		writeError(w, req, http.StatusConflict, "pass shelter, the animal id", repository.ErrAmbiguous)
+		return
+	}
+	animal := animals[0]
+
+	changes, err := a.History.AnimalHistory(ctx, animal.Key())
+	if err != nil {
+		writeError(w, req, http.StatusInternalServerError, "failed to get history", err)
+		return
+	}
+	if changes == nil {
+		changes = []repository.Change{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"animal":  animal.Key(),
+		"changes": changes,
+	})
+}
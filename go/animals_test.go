@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/repository"
+	"github.com/bbrombacher/animals/repository/mocks"
+	"github.com/gorilla/mux"
+	"go.uber.org/mock/gomock"
+)
+
+var biscuit = repository.Animal{ShelterID: "sonoma", ID: "A1", AnimalName: "Biscuit", AnimalType: "DOG"}
+
+func newTestController(t *testing.T) (AnimalController, *mocks.MockAnimalRepository) {
+	animals := mocks.NewMockAnimalRepository(gomock.NewController(t))
+	return AnimalController{Animals: animals}, animals
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	body := map[string]interface{}{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return body
+}
+
+func TestGetAnimalsRejectsBadParams(t *testing.T) {
+	cases := map[string]string{
+		"limit=0x10":                       "limit",
+		"limit=5000":                       "limit",
+		"limit=-1":                         "limit",
+		"cursor=**":                        "cursor",
+		"cursor=" + encodeCursor("sonoma"): "cursor",
+		"format=xml":                       "format",
+		"format=csv&limit=-2":              "limit",
+	}
+	for query, field := range cases {
+		t.Run(query, func(t *testing.T) {
+			// the mock fails the test if the repository is reached
+			controller, _ := newTestController(t)
+			rec := httptest.NewRecorder()
+			controller.GetAnimals(rec, httptest.NewRequest(http.MethodGet, "/v1/go-animals?"+query, nil))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d, want 400", rec.Code)
+			}
+			fields, _ := decodeBody(t, rec)["fields"].(map[string]interface{})
+			if _, ok := fields[field]; !ok {
+				t.Errorf("fields %v don't mention %s", fields, field)
+			}
+		})
+	}
+}
+
+func TestGetAnimalsQuery(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		principal auth.Principal
+		want      repository.AnimalQuery
+	}{
+		// one row past the page is asked for, to find the next
+		{name: "defaults", want: repository.AnimalQuery{AdoptableOnly: true, Limit: defaultLimit + 1}},
+		{name: "limit", query: "limit=5", want: repository.AnimalQuery{AdoptableOnly: true, Limit: 6}},
+		{name: "unknown params ignored", query: "limit=5&colour=red", want: repository.AnimalQuery{AdoptableOnly: true, Limit: 6}},
+		{name: "shelter", query: "shelter=sonoma", want: repository.AnimalQuery{ShelterID: "sonoma", AdoptableOnly: true, Limit: defaultLimit + 1}},
+		{name: "cursor", query: "limit=5&cursor=" + animalCursor(biscuit),
+			want: repository.AnimalQuery{AdoptableOnly: true, After: &repository.AnimalKey{ShelterID: "sonoma", ID: "A1"}, Limit: 6}},
+		{name: "volunteer sees everything", principal: auth.Principal{Subject: "v", Role: auth.RoleVolunteer}, want: repository.AnimalQuery{Limit: defaultLimit + 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller, animals := newTestController(t)
+			animals.EXPECT().List(gomock.Any(), tc.want).Return([]repository.Animal{biscuit}, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/go-animals?"+tc.query, nil)
+			if tc.principal != (auth.Principal{}) {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			controller.GetAnimals(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+			}
+			animalsJSON, _ := decodeBody(t, rec)["animals"].([]interface{})
+			if len(animalsJSON) != 1 {
+				t.Fatalf("got %d animals, want 1", len(animalsJSON))
+			}
+		})
+	}
+}
+
+func TestGetAnimalsPages(t *testing.T) {
+	second := repository.Animal{ShelterID: "sonoma", ID: "A2", AnimalName: "Pepper", AnimalType: "CAT"}
+	third := repository.Animal{ShelterID: "sonoma", ID: "A3", AnimalName: "Mochi", AnimalType: "CAT"}
+	controller, animals := newTestController(t)
+	gomock.InOrder(
+		animals.EXPECT().List(gomock.Any(), repository.AnimalQuery{AdoptableOnly: true, Limit: 3}).
+			Return([]repository.Animal{biscuit, second, third}, nil),
+		animals.EXPECT().List(gomock.Any(), repository.AnimalQuery{AdoptableOnly: true, After: &repository.AnimalKey{ShelterID: "sonoma", ID: "A2"}, Limit: 3}).
+			Return([]repository.Animal{third}, nil),
+	)
+
+	get := func(query string) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		controller.GetAnimals(rec, httptest.NewRequest(http.MethodGet, "/v1/go-animals?"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+		}
+		return decodeBody(t, rec)
+	}
+
+	first := get("limit=2")
+	if got, _ := first["animals"].([]interface{}); len(got) != 2 {
+		t.Fatalf("got %d animals on the first page, want 2", len(got))
+	}
+	cursor, _ := first["next_cursor"].(string)
+	if cursor != animalCursor(second) {
+		t.Fatalf("got next_cursor %q, want the second animal's", cursor)
+	}
+
+	last := get("limit=2&cursor=" + cursor)
+	if got, _ := last["animals"].([]interface{}); len(got) != 1 {
+		t.Fatalf("got %d animals on the last page, want 1", len(got))
+	}
+	if _, ok := last["next_cursor"]; ok {
+		t.Errorf("got next_cursor %v on the last page", last["next_cursor"])
+	}
+}
+
+func TestGetAnimalsRepositoryError(t *testing.T) {
+	controller, animals := newTestController(t)
+	animals.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	controller.GetAnimals(rec, httptest.NewRequest(http.MethodGet, "/v1/go-animals", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want 500", rec.Code)
+	}
+	if got := decodeBody(t, rec)["error"]; got != "failed to get data connection refused" {
+		t.Errorf("got error %q", got)
+	}
+}
+
+func TestGetAnimal(t *testing.T) {
+	cases := []struct {
+		name    string
+		shelter string
+		animal  repository.Animal
+		err     error
+		status  int
+	}{
+		{name: "found", animal: biscuit, status: http.StatusOK},
+		{name: "found in shelter", shelter: "sonoma", animal: biscuit, status: http.StatusOK},
+		{name: "not found", err: repository.ErrNotFound, status: http.StatusNotFound},
+		{name: "ambiguous", err: repository.ErrAmbiguous, status: http.StatusConflict},
+		{name: "repository error", err: errors.New("connection refused"), status: http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller, animals := newTestController(t)
+			animals.EXPECT().Get(gomock.Any(), tc.shelter, "A1", true).Return(tc.animal, tc.err)
+
+			target := "/v1/go-animals/A1"
+			if tc.shelter != "" {
+				target += "?shelter=" + tc.shelter
+			}
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, target, nil), map[string]string{"id": "A1"})
+			rec := httptest.NewRecorder()
+			controller.GetAnimal(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tc.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestExportAnimals(t *testing.T) {
+	controller, animals := newTestController(t)
+	animals.EXPECT().Stream(gomock.Any(), repository.AnimalQuery{AdoptableOnly: true}, gomock.Any()).
+		DoAndReturn(func(_ interface{}, _ repository.AnimalQuery, fn func(repository.Animal) error) error {
+			return fn(biscuit)
+		})
+
+	rec := httptest.NewRecorder()
+	controller.GetAnimals(rec, httptest.NewRequest(http.MethodGet, "/v1/go-animals?format=csv", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", rec.Code)
+	}
+	want := strings.Join(animalCSVHeader, ",") + "\nA1,Biscuit,DOG,,,,,,sonoma\n"
+	if rec.Body.String() != want {
+		t.Errorf("got body %q, want %q", rec.Body, want)
+	}
+}
+
+func TestExportAnimalsFailsBeforeFirstRow(t *testing.T) {
+	controller, animals := newTestController(t)
+	animals.EXPECT().Stream(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	controller.GetAnimals(rec, httptest.NewRequest(http.MethodGet, "/v1/go-animals?format=ndjson", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want 500", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got content type %q, want an error body", ct)
+	}
+}
+
+func TestGetAnimalHistory(t *testing.T) {
+	field := "outcome_type"
+	changes := []repository.Change{{
+		Entity: "intake", ShelterID: "sonoma", AnimalID: "A1", KennelNumber: "DS01", ImpoundNumber: "K1",
+		Operation: "UPDATE", Field: &field, OldValue: repository.RawJSON(`"TRANSFER"`), NewValue: repository.RawJSON(`"ADOPTION"`),
+		Source: "etl_run:2",
+	}}
+
+	ctrl := gomock.NewController(t)
+	animals := mocks.NewMockAnimalRepository(ctrl)
+	history := mocks.NewMockHistoryRepository(ctrl)
+	controller := AnimalController{Animals: animals, History: history}
+	animals.EXPECT().List(gomock.Any(), repository.AnimalQuery{ShelterID: "sonoma", IDs: []string{"A1"}, IncludeDeleted: true, Limit: 2}).
+		Return([]repository.Animal{biscuit}, nil)
+	history.EXPECT().AnimalHistory(gomock.Any(), biscuit.Key()).Return(changes, nil)
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/v1/go-animals/A1/history?shelter=sonoma", nil), map[string]string{"id": "A1"})
+	rec := httptest.NewRecorder()
+	controller.GetAnimalHistory(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Changes []struct {
+			Field    string
+			OldValue string
+			NewValue string
+			Source   string
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Changes) != 1 || body.Changes[0].Field != field || body.Changes[0].OldValue != "TRANSFER" ||
+		body.Changes[0].NewValue != "ADOPTION" || body.Changes[0].Source != "etl_run:2" {
+		t.Errorf("got changes %+v", body.Changes)
+	}
+}
+
+func TestGetAnimalHistoryUnknownAnimal(t *testing.T) {
+	cases := map[string]struct {
+		animals []repository.Animal
+		status  int
+	}{
+		"not found": {status: http.StatusNotFound},
+		"ambiguous": {animals: []repository.Animal{biscuit, {ShelterID: "marin", ID: "A1"}}, status: http.StatusConflict},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			animals := mocks.NewMockAnimalRepository(ctrl)
+			controller := AnimalController{Animals: animals, History: mocks.NewMockHistoryRepository(ctrl)}
+			animals.EXPECT().List(gomock.Any(), gomock.Any()).Return(tc.animals, nil)
+
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/v1/go-animals/A1/history", nil), map[string]string{"id": "A1"})
+			rec := httptest.NewRecorder()
+			controller.GetAnimalHistory(rec, req)
+
+			if rec.Code != tc.status {
+				t.Errorf("got status %d, want %d", rec.Code, tc.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/bbrombacher/animals/testdb"
+)
+
+func apiKeyRequest(key string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/v1/go-animals", nil)
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	return req
+}
+
+func TestAPIKeysAuthenticate(t *testing.T) {
+	db := testdb.New(t)
+	keys := map[string]string{}
+	for _, k := range []struct{ name, role string }{{"ci", "staff"}, {"old", "admin"}} {
+		key, err := NewAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[k.name] = key
+		if _, err := db.Exec("INSERT INTO api_keys (name, key_hash, role) VALUES ($1, $2, $3)", k.name, HashAPIKey(key), k.role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec("UPDATE api_keys SET revoked_at = NOW() WHERE name = 'old'"); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		key     string
+		want    Principal
+		wantErr error
+	}{
+		{name: "known key", key: keys["ci"], want: Principal{Subject: "apikey:ci", Role: RoleStaff}},
+		{name: "revoked key", key: keys["old"], wantErr: ErrInvalidCredentials},
+		{name: "unknown key", key: "not-a-key", wantErr: ErrInvalidCredentials},
+		// the stored hash isn't a key
+		{name: "hash of a key", key: HashAPIKey(keys["ci"]), wantErr: ErrInvalidCredentials},
+		{name: "no key", wantErr: ErrNoCredentials},
+	}
+	a := APIKeys{DB: db.DB}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := a.Authenticate(apiKeyRequest(tc.key))
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("got %+v, %v, want %v", got, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("got %+v, %v, want %+v", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeysWithoutKey(t *testing.T) {
+	// no key never reaches the database
+	if _, err := (APIKeys{}).Authenticate(apiKeyRequest("")); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("got %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestNewAPIKey(t *testing.T) {
+	a, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || len(a) != 43 {
+		t.Errorf("got keys %q and %q", a, b)
+	}
+	if HashAPIKey(a) != HashAPIKey(a) || HashAPIKey(a) == HashAPIKey(b) || len(HashAPIKey(a)) != 64 {
+		t.Error("HashAPIKey isn't a stable sha-256")
+	}
+}
@@ -0,0 +1,165 @@
+// Package auth authenticates API callers and gates routes by role.
+//
+// A request may carry an API key (X-API-Key header) or a JWT bearer token.
+// Requests without credentials are treated as the public role; requests with
+// credentials that fail verification are rejected.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bbrombacher/animals/logging"
+)
+
+// Role is a caller's access level. Roles are ordered, each one can do
+// everything the roles below it can.
+type Role int
+
+const (
+	RolePublic Role = iota
+	RoleVolunteer
+	RoleStaff
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RolePublic:    "public",
+	RoleVolunteer: "volunteer",
+	RoleStaff:     "staff",
+	RoleAdmin:     "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// ParseRole converts a stored or claimed role name to a Role.
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if strings.EqualFold(n, name) {
+			return role, nil
+		}
+	}
+	return RolePublic, fmt.Errorf("unknown role %q", name)
+}
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string
+	Role    Role
+}
+
+// Anonymous is the principal for requests without credentials.
+var Anonymous = Principal{Subject: "anonymous", Role: RolePublic}
+
+// CanSeeOperationalData reports whether p may see non-adoptable animals and
+// intake jurisdiction, location and zip code details.
+func (p Principal) CanSeeOperationalData() bool {
+	return p.Role >= RoleVolunteer
+}
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request
+	// carries no credentials it understands.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when credentials are present but
+	// can't be verified.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator verifies one kind of credential on a request.
+type Authenticator interface {
+	Authenticate(req *http.Request) (Principal, error)
+}
+
+type ctxKey int
+
+const principalKey ctxKey = iota
+
+// FromContext returns the principal stored by Middleware, or Anonymous.
+func FromContext(ctx context.Context) Principal {
+	p, ok := ctx.Value(principalKey).(Principal)
+	if !ok {
+		return Anonymous
+	}
+	return p
+}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// Middleware tries each authenticator in order and stores the first
+// principal found on the request context. Requests without credentials
+// continue as Anonymous.
+func Middleware(authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			principal, err := Authenticate(req, authenticators...)
+			if errors.Is(err, ErrInvalidCredentials) {
+				logging.FromContext(req.Context()).Warn("authentication failed", "error", err)
+				writeError(w, http.StatusUnauthorized, "invalid credentials")
+				return
+			}
+			if err != nil {
+				logging.FromContext(req.Context()).Error("authentication error", "error", err)
+				writeError(w, http.StatusInternalServerError, "failed to authenticate")
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(WithPrincipal(req.Context(), principal)))
+		})
+	}
+}
+
+// HasCredentials reports whether req carries an API key or a bearer token,
+// which an authenticator would check.
+func HasCredentials(req *http.Request) bool {
+	return req.Header.Get(APIKeyHeader) != "" || req.Header.Get("Authorization") != ""
+}
+
+// Authenticate returns the principal from the first authenticator that
+// finds credentials on req, or Anonymous when none do.
+func Authenticate(req *http.Request, authenticators ...Authenticator) (Principal, error) {
+	for _, a := range authenticators {
+		p, err := a.Authenticate(req)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return Anonymous, nil
+}
+
+// Require wraps next so only callers with at least role reach it.
+func Require(role Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p := FromContext(req.Context())
+		if p.Role >= role {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if p == Anonymous {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="animals"`)
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		writeError(w, http.StatusForbidden, fmt.Sprintf("requires %s role", role))
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": msg,
+	})
+}
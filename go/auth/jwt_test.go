@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signers are the keys tokens in these tests are signed with, by kid.
+var signers = func() map[string]crypto.Signer {
+	rsa1, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	rsa2, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return map[string]crypto.Signer{"rsa-1": rsa1, "rsa-2": rsa2, "ec-1": ec}
+}()
+
+// writeJWKS writes the public halves of the named signers as a JWKS file.
+func writeJWKS(t *testing.T, kids ...string) string {
+	t.Helper()
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	var keys []map[string]string
+	for _, kid := range kids {
+		switch pub := signers[kid].Public().(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{"kid": kid, "kty": "RSA", "use": "sig",
+				"n": b64(pub.N), "e": b64(big.NewInt(int64(pub.E)))})
+		case *ecdsa.PublicKey:
+			keys = append(keys, map[string]string{"kid": kid, "kty": "EC", "crv": "P-256", "x": b64(pub.X), "y": b64(pub.Y)})
+		}
+	}
+	// an encryption key is skipped
+	keys = append(keys, map[string]string{"kid": "enc", "kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"})
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writePEM writes signer's public key as a PEM file.
+func writePEM(t *testing.T, signer crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, c jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func bearer(token string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/v1/go-animals", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	j, err := NewJWT(JWTOptions{JWKSPath: writeJWKS(t, "rsa-1", "ec-1"), Issuer: "https://issuer.test", Audience: "animals", Leeway: 30 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	valid := func() claims {
+		return claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "user-1",
+				Issuer:    "https://issuer.test",
+				Audience:  jwt.ClaimStrings{"animals"},
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			},
+			Role: "staff",
+		}
+	}
+	with := func(edit func(*claims)) claims {
+		c := valid()
+		edit(&c)
+		return c
+	}
+	rsaPEM, err := os.ReadFile(writePEM(t, signers["rsa-1"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		// want is the principal, or zero for an invalid token
+		want Principal
+	}{
+		{name: "rsa key by kid", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], valid()),
+			want: Principal{Subject: "user-1", Role: RoleStaff}},
+		{name: "ec key by kid", token: sign(t, jwt.SigningMethodES256, "ec-1", signers["ec-1"], valid()),
+			want: Principal{Subject: "user-1", Role: RoleStaff}},
+		{name: "signed by another key than its kid's", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-2"], valid())},
+		{name: "unknown kid", token: sign(t, jwt.SigningMethodRS256, "rsa-2", signers["rsa-2"], valid())},
+		{name: "no kid", token: sign(t, jwt.SigningMethodRS256, "", signers["rsa-1"], valid())},
+		{name: "alg none", token: sign(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType, valid())},
+		{name: "hs256 keyed with the rsa public key", token: sign(t, jwt.SigningMethodHS256, "rsa-1", rsaPEM, valid())},
+		{name: "expired within the leeway", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], with(func(c *claims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second))
+		})), want: Principal{Subject: "user-1", Role: RoleStaff}},
+		{name: "expired", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], with(func(c *claims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute))
+		}))},
+		{name: "no expiry", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], with(func(c *claims) {
+			c.ExpiresAt = nil
+		}))},
+		{name: "not before, within the leeway", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], with(func(c *claims) {
+			c.NotBefore = jwt.NewNumericDate(now.Add(10 * time.Second))
+		})), want: Principal{Subject: "user-1", Role: RoleStaff}},
+		{name: "not yet valid", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], with(func(c *claims) {
+			c.NotBefore = jwt.NewNumericDate(now.Add(time.Minute))
+		}))},
+		{name: "wrong issuer", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], with(func(c *claims) {
+			c.Issuer = "https://elsewhere.test"
+		}))},
+		{name: "wrong audience", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], with(func(c *claims) {
+			c.Audience = jwt.ClaimStrings{"billing"}
+		}))},
+		{name: "unknown role", token: sign(t, jwt.SigningMethodRS256, "rsa-1", signers["rsa-1"], with(func(c *claims) {
+			c.Role = "root"
+		}))},
+		{name: "garbage", token: "not.a.token"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := j.Authenticate(bearer(tc.token))
+			if tc.want == (Principal{}) {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Errorf("got %+v, %v, want ErrInvalidCredentials", got, err)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("got %+v, %v, want %+v", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestJWTNoCredentials(t *testing.T) {
+	j, err := NewJWT(JWTOptions{JWKSPath: writeJWKS(t, "rsa-1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{"", "Basic dXNlcjpwYXNz", "Bearer "} {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		if _, err := j.Authenticate(req); !errors.Is(err, ErrNoCredentials) {
+			t.Errorf("got %v for Authorization %q, want ErrNoCredentials", err, header)
+		}
+	}
+}
+
+func TestJWTPEMKey(t *testing.T) {
+	j, err := NewJWT(JWTOptions{KeyPath: writePEM(t, signers["rsa-1"])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}, Role: "admin"}
+
+	// the one key verifies tokens whatever their kid
+	for _, kid := range []string{"", "rotated"} {
+		got, err := j.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, kid, signers["rsa-1"], c)))
+		if err != nil || got != (Principal{Subject: "user-1", Role: RoleAdmin}) {
+			t.Errorf("kid %q: got %+v, %v", kid, got, err)
+		}
+	}
+	if _, err := j.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, "", signers["rsa-2"], c))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("got %v for another key's token, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestNewJWTErrors(t *testing.T) {
+	if _, err := NewJWT(JWTOptions{}); err == nil {
+		t.Error("got no error without keys")
+	}
+	empty := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(empty, []byte(`{"keys":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJWT(JWTOptions{JWKSPath: empty}); err == nil {
+		t.Error("got no error for a jwks without signing keys")
+	}
+	if _, err := NewJWT(JWTOptions{KeyPath: empty}); err == nil {
+		t.Error("got no error for a key file that isn't PEM")
+	}
+}
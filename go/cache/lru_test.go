@@ -0,0 +1,56 @@
+package cache
+
+import "testing"
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[string, int](3)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+
+	// reading a and rewriting b leaves c the least recently used
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %d, %v, want 1, true", v, ok)
+	}
+	c.Add("b", 20)
+	c.Add("d", 4)
+
+	if _, ok := c.Get("c"); ok {
+		t.Error("c wasn't evicted")
+	}
+	for _, want := range []struct {
+		key   string
+		value int
+	}{{"a", 1}, {"b", 20}, {"d", 4}} {
+		if v, ok := c.Get(want.key); !ok || v != want.value {
+			t.Errorf("Get(%s) = %d, %v, want %d, true", want.key, v, ok, want.value)
+		}
+	}
+	if c.Len() != 3 {
+		t.Errorf("got %d entries, want 3", c.Len())
+	}
+
+	// the Gets above, in order, leave a the least recently used
+	c.Add("e", 5)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a wasn't evicted")
+	}
+}
+
+func TestLRUPurge(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Purge()
+
+	if c.Len() != 0 {
+		t.Errorf("got %d entries after Purge, want 0", c.Len())
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("a survived Purge")
+	}
+	c.Add("c", 3)
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("Get(c) = %d, %v after Purge, want 3, true", v, ok)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	goSql "database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/config"
+	_ "github.com/lib/pq"
+)
+
+// apikey creates an API key and stores its hash. The key itself is printed
+// once and can't be recovered.
+//
+//	apikey [flags] <name> <role>
+func main() {
+	cfg, err := config.Load("apikey", os.Args[1:], config.SectionDB)
+	if err != nil {
+		log.Fatalln("error loading config", err)
+	}
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+	if len(cfg.Args) != 2 {
+		log.Fatalln("usage: apikey [flags] <name> <public|volunteer|staff|admin>")
+	}
+	name := cfg.Args[0]
+	role, err := auth.ParseRole(cfg.Args[1])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	sqldb, err := goSql.Open("postgres", cfg.DB.URL)
+	if err != nil {
+		log.Fatalln("error opening sql", err.Error())
+	}
+	defer sqldb.Close()
+
+	key, err := auth.NewAPIKey()
+	if err != nil {
+		log.Fatalln("error generating key", err)
+	}
+
+	_, err = sqldb.ExecContext(context.Background(),
+		"INSERT INTO api_keys (name, key_hash, role) VALUES ($1, $2, $3)",
+		name, auth.HashAPIKey(key), role.String())
+	if err != nil {
+		log.Fatalln("error storing key", err)
+	}
+
+	fmt.Println(key)
+}
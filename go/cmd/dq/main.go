@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/bbrombacher/animals/config"
+	"github.com/bbrombacher/animals/dq"
+	"github.com/bbrombacher/animals/ingest"
+	"github.com/bbrombacher/animals/logging"
+	"github.com/bbrombacher/animals/repository"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// dq writes a data quality report on the CSV at -csv-path, read with the
+// profile -etl-profile, or with -dq-source db on the loaded tables.
+//
+//	dq -csv-path ../rawdata/sonoma_shelter_renamed.csv -dq-format html -dq-out report.html
+func main() {
+	cfg, err := config.Load("dq", os.Args[1:], config.SectionDB|config.SectionDQ|config.SectionLog)
+	if err != nil {
+		log.Fatalln("error loading config", err)
+	}
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+	if err := logging.SetDefault(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		log.Fatalln("error configuring logging", err)
+	}
+	ctx := context.Background()
+	opts := dq.Options{Now: time.Now(), Top: cfg.DQ.Top, Examples: cfg.DQ.Examples}
+
+	var profiler *dq.Profiler
+	switch cfg.DQ.Source {
+	case "csv":
+		profile, err := ingest.LookupProfile(cfg.ETL.Profile)
+		if err != nil {
+			fatal("unknown profile", err)
+		}
+		f, err := os.Open(cfg.CSVPath)
+		if err != nil {
+			fatal("error opening csv", err, "path", cfg.CSVPath)
+		}
+		defer f.Close()
+		src, err := ingest.NewCSVSource(f, cfg.CSVPath)
+		if err != nil {
+			fatal("error reading csv", err, "path", cfg.CSVPath)
+		}
+		profiler = dq.NewProfiler(cfg.CSVPath, opts)
+		if _, err := ingest.Run(ctx, src, profile, profiler); err != nil {
+			fatal("error profiling csv", err, "path", cfg.CSVPath)
+		}
+	case "db":
+		db, err := sqlx.Open("postgres", cfg.DB.URL)
+		if err != nil {
+			fatal("error opening sql", err)
+		}
+		defer db.Close()
+		profiler = dq.NewProfiler("database", opts)
+		err = profiler.AddTables(ctx, repository.PostgresAnimals{DB: db}, repository.PostgresIntakes{DB: db})
+		if err != nil {
+			fatal("error profiling tables", err)
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.DQ.Out != "" {
+		f, err := os.Create(cfg.DQ.Out)
+		if err != nil {
+			fatal("error creating output", err, "path", cfg.DQ.Out)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	report := profiler.Report()
+	err = dq.Formats[cfg.DQ.Format](w, report)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		fatal("error writing report", err)
+	}
+
+	failed := 0
+	for _, c := range report.Checks {
+		failed += c.Failed
+	}
+	slog.Info("data quality report written", "source", report.Source, "animals", report.Animals,
+		"intakes", report.Intakes, "malformed", report.Malformed, "failed_checks", failed)
+}
+
+// fatal logs msg with err and any extra attributes, then exits.
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
+}
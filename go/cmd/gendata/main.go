@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/bbrombacher/animals/config"
+	"github.com/bbrombacher/animals/logging"
+	"github.com/bbrombacher/animals/synth"
+)
+
+// gendata writes synthetic shelter data in the Sonoma export's layout, for
+// loading with cmd/etl when the real file isn't at hand.
+//
+//	gendata -gen-rows 300000 -gen-out ../rawdata/synthetic.csv
+func main() {
+	cfg, err := config.Load("gendata", os.Args[1:], config.SectionGen|config.SectionLog)
+	if err != nil {
+		log.Fatalln("error loading config", err)
+	}
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+	if err := logging.SetDefault(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		log.Fatalln("error configuring logging", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if cfg.Gen.Out != "" {
+		f, err := os.Create(cfg.Gen.Out)
+		if err != nil {
+			fatal("error creating output", err, "path", cfg.Gen.Out)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+
+	stats, err := synth.Generate(w, synth.Options{
+		Rows:          cfg.Gen.Rows,
+		Seed:          cfg.Gen.Seed,
+		RepeatRate:    cfg.Gen.RepeatRate,
+		MissingRate:   cfg.Gen.MissingRate,
+		MalformedRate: cfg.Gen.MalformedRate,
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		fatal("error writing synthetic data", err)
+	}
+	slog.Info("synthetic data written", "rows", stats.Rows, "malformed", stats.Malformed,
+		"animals", stats.Animals, "intakes", stats.Intakes)
+}
+
+// fatal logs msg with err and any extra attributes, then exits.
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
+}
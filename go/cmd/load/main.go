@@ -1,40 +1,68 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/andybalholm/brotli"
+	"github.com/bbrombacher/animals/config"
+	"github.com/bbrombacher/animals/logging"
+	"github.com/bbrombacher/animals/tracing"
 	"github.com/gammazero/workerpool"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-// local
-var baseLocalUrl = "http://localhost:8080"
-
-// express
-var expressEndpoint = "/api/v1/express-animals?limit=%v"
-var expressBaseURL = "https://express-animals-production.up.railway.app"
+func main() {
+	cfg, err := config.Load("load", os.Args[1:], config.SectionLoad|config.SectionLog|config.SectionTracing)
+	if err != nil {
+		log.Fatalln("error loading config", err)
+	}
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
+	if err := logging.SetDefault(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		log.Fatalln("error configuring logging", err)
+	}
 
-// go
-var goEndpoint = "/v1/go-animals?limit=%v"
-var goBaseURL = "https://animals-production.up.railway.app"
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, "animals-load")
+	if err != nil {
+		slog.Error("error configuring tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	// the otel transport injects a traceparent header so server spans join
+	// the load tool's trace
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Load.Encoding != "" {
+		// decode ourselves so the bytes on the wire can be counted
+		transport.DisableCompression = true
+	}
+	client := &http.Client{Transport: otelhttp.NewTransport(transport)}
+	stats := &TransferStats{}
 
-func main() {
 	s1 := rand.NewSource(time.Now().UnixNano())
 	r1 := rand.New(s1)
 
-	url := expressBaseURL + expressEndpoint
-	//url := goBaseURL + goEndpoint
+	url := cfg.Load.BaseURL + cfg.Load.Endpoint
 
-	wp := workerpool.New(5)
+	wp := workerpool.New(cfg.Load.Workers)
 	start := time.Now()
-	loops := 20000
+	loops := cfg.Load.Requests
 	httpErrs := make([]error, 0, 100)
 	//badResponse := make([]map[string]interface{}, 0, 100)
 	nilRepsonse := make([]map[string]interface{}, 0, 100)
@@ -49,17 +77,20 @@ func main() {
 			limit := r1.Intn(100)
 			limit++
 			reqStart := time.Now()
-			resp, err := makeRequest(limit, url)
+			ctx, span := tracing.Tracer.Start(context.Background(), "load.request")
+			defer span.End()
+			resp, err := makeRequest(ctx, client, limit, url, cfg.Load.Encoding, stats)
 			if err != nil {
 				httpErrs = append(httpErrs, err)
-				log.Fatalln("error getting data", err)
+				slog.Error("error getting data", "error", err)
+				os.Exit(1)
 			}
 
 			elapsed := time.Since(reqStart)
 			if resp != nil {
 				animals, ok := resp["animals"].([]interface{})
 				if ok {
-					log.Println("len of animals:", len(animals), elapsed)
+					slog.Info("response", "animals", len(animals), "latency", elapsed, "trace_id", span.SpanContext().TraceID().String())
 				}
 			} else {
 				nilRepsonse = append(nilRepsonse, resp)
@@ -73,13 +104,51 @@ func main() {
 
 	elapsed := time.Since(start)
 	rps := loops / int(elapsed.Seconds())
-	log.Println("rps: ", rps)
+	slog.Info("load complete", "requests", loops, "elapsed", elapsed, "rps", rps)
 	/*
 		log.Println("error count:", len(httpErrs))
 		log.Println("bad response count:", len(badResponse))
 		log.Println("nil response count:", len(nilRepsonse))
 	*/
 	buckets.String()
+	stats.String(cfg.Load.Encoding)
+}
+
+// TransferStats totals response body sizes as sent and once decoded.
+type TransferStats struct {
+	Responses    atomic.Int64
+	WireBytes    atomic.Int64
+	DecodedBytes atomic.Int64
+}
+
+func (t *TransferStats) String(encoding string) {
+	if encoding == "" {
+		slog.Info("wire bytes unknown, set -load-encoding to measure them")
+		return
+	}
+	responses, wire, decoded := t.Responses.Load(), t.WireBytes.Load(), t.DecodedBytes.Load()
+	if responses == 0 || wire == 0 {
+		return
+	}
+	slog.Info("transfer",
+		"encoding", encoding,
+		"wire_bytes", wire,
+		"decoded_bytes", decoded,
+		"avg_wire_bytes", wire/responses,
+		"ratio", float64(decoded)/float64(wire),
+	)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
 }
 
 type Buckets struct {
@@ -110,27 +179,51 @@ func (b *Buckets) BucketTime(time string) {
 func (b *Buckets) String() {
 	order := []string{"0-50", "51-200", "201-500", "501-1000", "1000++"}
 	for _, key := range order {
-		log.Println(key, b.Bucks[key])
+		slog.Info("latency bucket", "ms", key, "count", b.Bucks[key])
 	}
 }
 
-func makeRequest(limit int, url string) (map[string]interface{}, error) {
+func makeRequest(ctx context.Context, client *http.Client, limit int, url, encoding string, stats *TransferStats) (map[string]interface{}, error) {
 
-	resp, err := http.DefaultClient.Get(fmt.Sprintf(url, limit))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(url, limit), nil)
 	if err != nil {
 		return nil, err
 	}
+	if encoding != "" {
+		req.Header.Set("Accept-Encoding", encoding)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode > 299 {
 		return nil, fmt.Errorf("bad status code %v", resp.StatusCode)
 	}
 
+	var body io.Reader = countingReader{r: resp.Body, n: &stats.WireBytes}
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		gr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		body = gr
+	case "br":
+		body = brotli.NewReader(body)
+	}
+	body = countingReader{r: body, n: &stats.DecodedBytes}
+	stats.Responses.Add(1)
+
 	response := map[string]interface{}{}
-	err = json.NewDecoder(resp.Body).Decode(&response)
+	err = json.NewDecoder(body).Decode(&response)
 	if err != nil {
-		log.Println("faield to decode body")
+		slog.Error("failed to decode body", "error", err)
 		return nil, err
 	}
+	// drain the rest so the byte counts cover the whole body
+	io.Copy(io.Discard, body)
 
 	return response, nil
 }
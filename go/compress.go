@@ -0,0 +1,204 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	encodingGzip   = "gzip"
+	encodingBrotli = "br"
+)
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	}}
+	brotliWriters = sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	}}
+)
+
+// compressMiddleware compresses responses of at least minSize bytes with
+// brotli or gzip, whichever the client prefers.
+func compressMiddleware(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"))
+			if encoding == "" || req.Method == http.MethodHead {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+			defer cw.Close()
+			next.ServeHTTP(cw, req)
+		})
+	}
+}
+
+// negotiateEncoding returns the supported encoding with the highest q-value
+// in an Accept-Encoding header, preferring brotli on ties, or "" for none.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 || (name != encodingGzip && name != encodingBrotli) {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == encodingBrotli) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the first minSize bytes of a response to decide
+// whether compressing it is worthwhile.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status      int
+	buf         []byte
+	decided     bool
+	compressing bool
+	enc         io.WriteCloser
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if c.decided {
+		if c.compressing {
+			return c.enc.Write(b)
+		}
+		return c.ResponseWriter.Write(b)
+	}
+
+	c.buf = append(c.buf, b...)
+	if len(c.buf) < c.minSize {
+		return len(b), nil
+	}
+	if err := c.decide(true); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// decide sends the headers and buffered bytes, compressed if big is set and
+// the response is eligible.
+func (c *compressWriter) decide(big bool) error {
+	c.decided = true
+	h := c.Header()
+	c.compressing = big && c.status == http.StatusOK && h.Get("Content-Encoding") == ""
+
+	if c.compressing {
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", http.DetectContentType(c.buf))
+		}
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+		// the compressed body is a different representation
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		switch c.encoding {
+		case encodingBrotli:
+			bw := brotliWriters.Get().(*brotli.Writer)
+			bw.Reset(c.ResponseWriter)
+			c.enc = bw
+		default:
+			gw := gzipWriters.Get().(*gzip.Writer)
+			gw.Reset(c.ResponseWriter)
+			c.enc = gw
+		}
+	}
+
+	c.ResponseWriter.WriteHeader(c.status)
+	if len(c.buf) == 0 {
+		return nil
+	}
+	var err error
+	if c.compressing {
+		_, err = c.enc.Write(c.buf)
+	} else {
+		_, err = c.ResponseWriter.Write(c.buf)
+	}
+	c.buf = nil
+	return err
+}
+
+// Flush sends what has been compressed so far, so streamed exports keep
+// flowing.
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		if c.status == 0 {
+			return
+		}
+		c.decide(true)
+	}
+	if c.compressing {
+		switch enc := c.enc.(type) {
+		case *gzip.Writer:
+			enc.Flush()
+		case *brotli.Writer:
+			enc.Flush()
+		}
+	}
+	http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// Close finishes the response, writing out a short body uncompressed.
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		if c.status == 0 {
+			return nil
+		}
+		if err := c.decide(false); err != nil {
+			return err
+		}
+	}
+	if !c.compressing {
+		return nil
+	}
+	err := c.enc.Close()
+	switch enc := c.enc.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(enc)
+	case *brotli.Writer:
+		brotliWriters.Put(enc)
+	}
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
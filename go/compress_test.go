@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"gzip":                     encodingGzip,
+		"br":                       encodingBrotli,
+		"gzip, br":                 encodingBrotli,
+		"br, gzip":                 encodingBrotli,
+		"gzip;q=1.0, br;q=0.5":     encodingGzip,
+		"gzip;q=0.2, br;q=0.8":     encodingBrotli,
+		"GZIP":                     encodingGzip,
+		"gzip;q=0, br;q=0":         "",
+		"br;q=0, gzip":             encodingGzip,
+		"identity;q=0":             "",
+		"identity;q=0, gzip;q=0.1": encodingGzip,
+		"deflate, compress, *;q=0": "",
+		"gzip;q=abc, br;q=0.1":     encodingBrotli,
+	}
+	for header, want := range tests {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+// decompressBody decompresses a recorded response by its Content-Encoding.
+func decompressBody(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var r io.Reader = rec.Body
+	switch rec.Header().Get("Content-Encoding") {
+	case encodingGzip:
+		gr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gr
+	case encodingBrotli:
+		r = brotli.NewReader(rec.Body)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	const minSize = 64
+	big := strings.Repeat(`{"id":"A100001"}`, 16)
+	small := `{"id":"A100001"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		method         string
+		status         int
+		body           string
+		wantEncoding   string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", body: big, wantEncoding: encodingGzip},
+		{name: "brotli preferred", acceptEncoding: "gzip, br", body: big, wantEncoding: encodingBrotli},
+		{name: "under the minimum size", acceptEncoding: "gzip, br", body: small},
+		{name: "no accept-encoding", body: big},
+		{name: "identity only", acceptEncoding: "identity;q=0", body: big},
+		{name: "head", acceptEncoding: "gzip", method: http.MethodHead, body: big},
+		{name: "error status", acceptEncoding: "gzip", status: http.StatusInternalServerError, body: big},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := compressMiddleware(minSize)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"v1"`)
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				io.WriteString(w, tc.body)
+			}))
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/v1/go-animals", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("got Vary %q, want Accept-Encoding", got)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Fatalf("got Content-Encoding %q, want %q", got, tc.wantEncoding)
+			}
+			// the ETag only names a different representation once the body
+			// is compressed
+			wantETag := `"v1"`
+			if tc.wantEncoding != "" {
+				wantETag = `W/"v1"`
+			}
+			if got := rec.Header().Get("ETag"); got != wantETag {
+				t.Errorf("got ETag %q, want %q", got, wantETag)
+			}
+			if method == http.MethodHead {
+				return
+			}
+			if got := decompressBody(t, rec); got != tc.body {
+				t.Errorf("got body %q, want %q", got, tc.body)
+			}
+		})
+	}
+}
+
+// TestCompressFlush checks that each flush of a streamed export reaches the
+// client as a chunk it can decode before the response ends.
+func TestCompressFlush(t *testing.T) {
+	next := make(chan struct{})
+	srv := httptest.NewServer(compressMiddleware(1024)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		rc := http.NewResponseController(w)
+		for _, line := range []string{`{"id":"A100001"}`, `{"id":"A100002"}`} {
+			io.WriteString(w, line+"\n")
+			if err := rc.Flush(); err != nil {
+				t.Error(err)
+			}
+			<-next
+		}
+	})))
+	defer srv.Close()
+	defer close(next)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// set by hand, so the transport leaves the body compressed
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Encoding"); got != encodingGzip {
+		t.Fatalf("got Content-Encoding %q, want gzip", got)
+	}
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bufio.NewReader(gr)
+	for _, want := range []string{`{"id":"A100001"}`, `{"id":"A100002"}`} {
+		// the handler waits for each line to be read before writing the
+		// next, so this only passes if the flush got it here
+		got, err := lines.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want+"\n" {
+			t.Errorf("got line %q, want %q", got, want)
+		}
+		next <- struct{}{}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/config"
+	"github.com/bbrombacher/animals/testdb"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+func loadSpec(t *testing.T) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		t.Fatalf("loading openapi.json: %v", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	return doc
+}
+
+func testConfig(t *testing.T, args ...string) config.Config {
+	t.Helper()
+	cfg, err := config.Load("test", append([]string{"-migrations-path", "../migrations"}, args...), configSections)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	return cfg
+}
+
+// TestOpenAPIRoutes checks that every routed path is documented and every
+// documented path is routed.
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := loadSpec(t)
+	cfg := testConfig(t)
+	r := newRouter(cfg, nil, nil, nil, NewHealth(nil, cfg), nil, nil)
+
+	routed := map[string]bool{}
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		routed[tmpl] = true
+		if doc.Paths.Value(tmpl) == nil {
+			t.Errorf("route %s is missing from openapi.json", tmpl)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path := range doc.Paths.Map() {
+		if !routed[path] {
+			t.Errorf("openapi.json documents %s, which isn't routed", path)
+		}
+	}
+}
+
+// contractCase is one request made against the running server.
+type contractCase struct {
+	name   string
+	method string
+	path   string
+	header http.Header
+	body   string
+	status int
+}
+
+// TestContract starts the API against a test database and validates real
+// requests and responses against openapi.json.
+func TestContract(t *testing.T) {
+	testDB := testdb.New(t)
+	db := testDB.DB
+	seedContractData(t, db)
+
+	cfg := testConfig(t, "-db-url", testDB.URL)
+	cache := NewResponseCache(db, cfg.Cache.Size, cfg.Cache.MaxAge)
+	r := newRouter(cfg, db, cache, nil, NewHealth(db, cfg), []auth.Authenticator{auth.APIKeys{DB: db}}, nil)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	doc := loadSpec(t)
+	doc.Servers = openapi3.Servers{{URL: srv.URL}}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []contractCase{
+		{name: "list", method: http.MethodGet, path: "/v1/go-animals", status: http.StatusOK},
+		{name: "list with limit", method: http.MethodGet, path: "/v1/go-animals?limit=1", status: http.StatusOK},
+		{name: "list csv", method: http.MethodGet, path: "/v1/go-animals", header: http.Header{"Accept": {"text/csv"}}, status: http.StatusOK},
+		{name: "list ndjson", method: http.MethodGet, path: "/v1/go-animals?format=ndjson", status: http.StatusOK},
+		{name: "list bad limit", method: http.MethodGet, path: "/v1/go-animals?limit=5000", status: http.StatusBadRequest},
+		{name: "list not modified", method: http.MethodGet, path: "/v1/go-animals", header: http.Header{"If-None-Match": {"*"}}, status: http.StatusNotModified},
+		{name: "get", method: http.MethodGet, path: "/v1/go-animals/contract-adoptable", status: http.StatusOK},
+		{name: "get hidden from public", method: http.MethodGet, path: "/v1/go-animals/contract-adopted", status: http.StatusNotFound},
+		{name: "get missing", method: http.MethodGet, path: "/v1/go-animals/contract-missing", status: http.StatusNotFound},
+		{name: "list by shelter", method: http.MethodGet, path: "/v1/go-animals?shelter=contract", status: http.StatusOK},
+		{name: "get in shelter", method: http.MethodGet, path: "/v1/go-animals/contract-shared?shelter=contract", status: http.StatusOK},
+		{name: "get ambiguous", method: http.MethodGet, path: "/v1/go-animals/contract-shared", status: http.StatusConflict},
+		{name: "bad api key", method: http.MethodGet, path: "/v1/go-animals", header: http.Header{"X-Api-Key": {"not-a-key"}}, status: http.StatusUnauthorized},
+		{name: "history needs staff", method: http.MethodGet, path: "/v1/go-animals/contract-adoptable/history", status: http.StatusUnauthorized},
+		{name: "debug needs admin", method: http.MethodGet, path: "/v1/debug", status: http.StatusUnauthorized},
+		{name: "ingest status needs admin", method: http.MethodGet, path: "/v1/admin/ingest", status: http.StatusUnauthorized},
+		{name: "openapi", method: http.MethodGet, path: "/v1/openapi.json", status: http.StatusOK},
+		{name: "graphql", method: http.MethodPost, path: "/graphql", header: http.Header{"Content-Type": {"application/json"}}, body: `{"query":"{ animals(first: 2) { edges { node { id name } } } }"}`, status: http.StatusOK},
+		{name: "healthz", method: http.MethodGet, path: "/healthz", status: http.StatusOK},
+		{name: "readyz", method: http.MethodGet, path: "/readyz", status: http.StatusOK},
+		{name: "metrics", method: http.MethodGet, path: "/metrics", status: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checkContract(t, router, srv.URL, tc)
+		})
+	}
+}
+
+func checkContract(t *testing.T, router routers.Router, baseURL string, tc contractCase) {
+	t.Helper()
+	ctx := context.Background()
+
+	req, err := http.NewRequest(tc.method, baseURL+tc.path, strings.NewReader(tc.body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range tc.header {
+		req.Header[k] = v
+	}
+
+	route, pathParams, err := router.FindRoute(req)
+	if err != nil {
+		t.Fatalf("%s %s is not in openapi.json: %v", tc.method, tc.path, err)
+	}
+	reqInput := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}
+	// bad inputs are sent on purpose to check the error responses
+	if tc.status < 400 {
+		if err := openapi3filter.ValidateRequest(ctx, reqInput); err != nil {
+			t.Fatalf("request doesn't match openapi.json: %v", err)
+		}
+	}
+	req.Body = io.NopCloser(strings.NewReader(tc.body))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != tc.status {
+		t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tc.status, body)
+	}
+
+	opts := &openapi3filter.Options{IncludeResponseStatus: true}
+	ndjson := strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-ndjson")
+	if ndjson {
+		// the spec describes a single line; each one is checked below
+		opts.ExcludeResponseBody = true
+	}
+	respInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: reqInput,
+		Status:                 resp.StatusCode,
+		Header:                 resp.Header,
+		Options:                opts,
+	}
+	respInput.SetBodyBytes(body)
+	if err := openapi3filter.ValidateResponse(ctx, respInput); err != nil {
+		t.Fatalf("response doesn't match openapi.json: %v", err)
+	}
+	if ndjson {
+		checkNDJSON(t, route, resp.StatusCode, body)
+	}
+}
+
+func checkNDJSON(t *testing.T, route *routers.Route, status int, body []byte) {
+	t.Helper()
+	schema := route.Operation.Responses.Status(status).Value.Content.Get("application/x-ndjson").Schema.Value
+	lines := bufio.NewScanner(bytes.NewReader(body))
+	for lines.Scan() {
+		var v any
+		if err := json.Unmarshal(lines.Bytes(), &v); err != nil {
+			t.Fatalf("invalid ndjson line %q: %v", lines.Text(), err)
+		}
+		if err := schema.VisitJSON(v); err != nil {
+			t.Fatalf("ndjson line doesn't match openapi.json: %v", err)
+		}
+	}
+}
+
+// seedContractData makes sure there is one adoptable animal, one that has
+// left the shelter and an id used by two shelters.
+func seedContractData(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+	statements := []string{
+		`INSERT INTO shelters (id, name) VALUES ('contract', 'Contract Test Shelter') ON CONFLICT (id) DO NOTHING`,
+		`INSERT INTO animals (shelter_id, id, animal_name, animal_type, breed, color, sex, animal_size, date_of_birth)
+		VALUES ('sonoma', 'contract-adoptable', 'Biscuit', 'DOG', 'BEAGLE', 'TAN', 'Male', 'MED', '2019-04-01'),
+			('sonoma', 'contract-adopted', 'Mochi', 'CAT', 'DOMESTIC SH', 'BLACK', 'Female', 'SMALL', NULL),
+			('sonoma', 'contract-shared', 'Pepper', 'DOG', 'POODLE', 'GRAY', 'Female', 'SMALL', NULL),
+			('contract', 'contract-shared', 'Salt', 'CAT', 'SIAMESE', 'WHITE', 'Male', 'SMALL', NULL)
+		ON CONFLICT (shelter_id, id) DO NOTHING`,
+		`INSERT INTO animal_intake (shelter_id, impound_number, kennel_number, animal_id, intake_date, outcome_date, days_in_shelter,
+			intake_type, intake_subtype, outcome_type, outcome_subtype, intake_condition, outcome_condition,
+			intake_jurisdiction, outcome_jurisdiction, location, animal_count, zip_code)
+		VALUES ('sonoma', 'K-CONTRACT-1', 'DS01', 'contract-adoptable', '2024-01-02', NULL, 10,
+			'STRAY', 'FIELD', '', '', 'HEALTHY', '', 'SANTA ROSA', '', 'SANTA ROSA', 1, 95401),
+			('sonoma', 'K-CONTRACT-2', 'CS02', 'contract-adopted', '2024-01-03', '2024-02-01', 29,
+			'OWNER SURRENDER', 'OVER THE COUNTER', 'ADOPTION', 'INTERNET', 'HEALTHY', 'HEALTHY', 'COUNTY', 'COUNTY', 'COUNTY', 1, 95404),
+			('sonoma', 'K-CONTRACT-3', 'DS02', 'contract-shared', '2024-01-04', NULL, 8,
+			'STRAY', 'FIELD', '', '', 'HEALTHY', '', 'COUNTY', '', 'COUNTY', 1, 95404),
+			('contract', 'K-CONTRACT-3', 'CS01', 'contract-shared', '2024-01-04', NULL, 8,
+			'STRAY', 'FIELD', '', '', 'HEALTHY', '', 'COUNTY', '', 'COUNTY', 1, 95404)
+		ON CONFLICT DO NOTHING`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("seeding contract data: %v", err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Debug struct {
+	DB *sqlx.DB
+}
+
+func (d Debug) GetDBStats(w http.ResponseWriter, req *http.Request) {
+	resp := map[string]interface{}{
+		"db_stats": d.DB.Stats(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,319 @@
+// Package dq profiles shelter data for a data quality report: how often each
+// column is missing or "unknown", the common values of categorical columns,
+// and checks that dates, days in shelter and keys agree. The county's data is
+// messy and rawdata/header_breakdown.txt is all the documentation it has, so
+// cmd/dq runs this over an export or the loaded tables before trusting them.
+package dq
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbrombacher/animals/ingest"
+	"github.com/bbrombacher/animals/repository"
+)
+
+// Options tune a report.
+type Options struct {
+	// Now is when dates stop being in the future.
+	Now time.Time
+	// Top is how many of each categorical column's values are listed.
+	Top int
+	// Examples is how many failing rows each check lists.
+	Examples int
+}
+
+// Categorical columns get their distinct values counted and listed.
+var (
+	AnimalCategories = []string{"animal_type", "breed", "color", "sex", "animal_size"}
+	IntakeCategories = []string{"intake_type", "intake_subtype", "outcome_type", "outcome_subtype",
+		"intake_condition", "outcome_condition", "intake_jurisdiction", "outcome_jurisdiction"}
+)
+
+// zeroIsMissing lists int columns a missing value loads as 0 in.
+var zeroIsMissing = map[string]bool{"zip_code": true}
+
+// Profiler collects animals and intakes into a report. It is an
+// ingest.Sink, so a source can be profiled with ingest.Run.
+type Profiler struct {
+	opts   Options
+	source string
+	stats  *ingest.Stats
+
+	// animals are kept by key, as a file repeats an animal on each of its
+	// intakes; their columns are profiled once all are in.
+	animals       map[repository.AnimalKey]repository.Animal
+	intakes       int
+	intakeColumns []*column
+	intakeKeys    map[repository.IntakeKey]bool
+	// intakeAnimals are the animals intakes belong to, by the first
+	// intake seen of each.
+	intakeAnimals map[repository.AnimalKey]repository.IntakeKey
+	checks        map[string]*Check
+}
+
+var _ ingest.Sink = (*Profiler)(nil)
+
+// NewProfiler returns a profiler of the data from source.
+func NewProfiler(source string, opts Options) *Profiler {
+	p := &Profiler{
+		opts:          opts,
+		source:        source,
+		animals:       map[repository.AnimalKey]repository.Animal{},
+		intakeColumns: columnsOf(repository.Intake{}, IntakeCategories),
+		intakeKeys:    map[repository.IntakeKey]bool{},
+		intakeAnimals: map[repository.AnimalKey]repository.IntakeKey{},
+		checks:        map[string]*Check{},
+	}
+	for _, c := range checks {
+		if c.intake {
+			check := c.Check
+			p.checks[c.Name] = &check
+		}
+	}
+	return p
+}
+
+func (p *Profiler) Begin(ctx context.Context, source string) error {
+	p.source = source
+	return nil
+}
+
+func (p *Profiler) Write(ctx context.Context, row ingest.Row) error {
+	p.AddAnimal(row.Animal)
+	p.AddIntake(row.Intake)
+	return nil
+}
+
+// Finish keeps the load's stats, for the malformed row count.
+func (p *Profiler) Finish(ctx context.Context, stats ingest.Stats) error {
+	p.stats = &stats
+	return nil
+}
+
+// AddAnimal profiles an animal. A repeated animal replaces the earlier one.
+func (p *Profiler) AddAnimal(a repository.Animal) {
+	p.animals[a.Key()] = a
+}
+
+// AddIntake profiles an intake.
+func (p *Profiler) AddIntake(i repository.Intake) {
+	p.intakes++
+	addRow(p.intakeColumns, i)
+
+	key := i.Key()
+	name := fmt.Sprintf("%s %s %s", i.AnimalID, i.KennelNumber, i.ImpoundNumber)
+	p.check(CheckDuplicateIntake, p.intakeKeys[key], name)
+	p.intakeKeys[key] = true
+	if _, ok := p.intakeAnimals[i.AnimalKey()]; !ok {
+		p.intakeAnimals[i.AnimalKey()] = key
+	}
+
+	p.check(CheckIntakeInFuture, i.IntakeDate != nil && i.IntakeDate.After(p.opts.Now),
+		fmt.Sprintf("%s: intake %s", name, formatDate(i.IntakeDate)))
+	if i.IntakeDate == nil || i.OutcomeDate == nil {
+		return
+	}
+	p.check(CheckOutcomeBeforeIntake, i.OutcomeDate.Before(*i.IntakeDate),
+		fmt.Sprintf("%s: intake %s, outcome %s", name, formatDate(i.IntakeDate), formatDate(i.OutcomeDate)))
+	days := daysBetween(*i.IntakeDate, *i.OutcomeDate)
+	p.check(CheckDaysInShelter, i.DaysInShelter != days,
+		fmt.Sprintf("%s: %d days in shelter, %d between intake %s and outcome %s",
+			name, i.DaysInShelter, days, formatDate(i.IntakeDate), formatDate(i.OutcomeDate)))
+}
+
+// check counts a row an intake check looked at, and whether it failed.
+func (p *Profiler) check(name string, failed bool, example string) {
+	p.checks[name].count(failed, example, p.opts.Examples)
+}
+
+// Report summarises everything added so far.
+func (p *Profiler) Report() Report {
+	r := Report{
+		Source:      p.source,
+		GeneratedAt: p.opts.Now,
+		Animals:     len(p.animals),
+		Intakes:     p.intakes,
+	}
+	if p.stats != nil {
+		r.Records, r.Malformed = p.stats.Read, p.stats.Skipped
+	}
+
+	// the animal checks run now that every animal is in
+	animalChecks := map[string]*Check{}
+	for _, c := range checks {
+		if !c.intake {
+			check := c.Check
+			animalChecks[c.Name] = &check
+		}
+	}
+	animalColumns := columnsOf(repository.Animal{}, AnimalCategories)
+	for _, key := range sortedKeys(p.animals) {
+		a := p.animals[key]
+		addRow(animalColumns, a)
+		animalChecks[CheckBirthInFuture].count(a.DateOfBirth != nil && a.DateOfBirth.After(p.opts.Now),
+			fmt.Sprintf("%s: born %s", a.ID, formatDate(a.DateOfBirth)), p.opts.Examples)
+	}
+	for _, key := range sortedKeys(p.intakeAnimals) {
+		_, ok := p.animals[key]
+		intake := p.intakeAnimals[key]
+		animalChecks[CheckOrphanIntake].count(!ok, fmt.Sprintf("%s %s %s", key.ID, intake.KennelNumber, intake.ImpoundNumber), p.opts.Examples)
+	}
+
+	r.AnimalColumns, r.AnimalCategories = summarise(animalColumns, r.Animals, p.opts.Top)
+	r.IntakeColumns, r.IntakeCategories = summarise(p.intakeColumns, r.Intakes, p.opts.Top)
+	for _, c := range checks {
+		check, ok := p.checks[c.Name]
+		if !ok {
+			check = animalChecks[c.Name]
+		}
+		r.Checks = append(r.Checks, *check)
+	}
+	return r
+}
+
+// sortedKeys returns m's keys in order, so reports list examples the same
+// way every time.
+func sortedKeys[V any](m map[repository.AnimalKey]V) []repository.AnimalKey {
+	keys := make([]repository.AnimalKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ShelterID != keys[j].ShelterID {
+			return keys[i].ShelterID < keys[j].ShelterID
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// daysBetween counts the calendar days from one date to another, ignoring
+// their times and any daylight saving change between them.
+func daysBetween(from, to time.Time) int {
+	civil := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return int(civil(to).Sub(civil(from)).Hours() / 24)
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return "none"
+	}
+	return t.Format(time.DateOnly)
+}
+
+// column profiles one column of a model, found by its db tag.
+type column struct {
+	name        string
+	index       int
+	kind        reflect.Kind
+	date        bool
+	categorical bool
+
+	missing  int
+	unknown  int
+	values   map[string]int
+	min, max *time.Time
+}
+
+// columnsOf returns a column for each db tagged field of model.
+func columnsOf(model any, categorical []string) []*column {
+	t := reflect.TypeOf(model)
+	var cols []*column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("db")
+		if name == "" {
+			continue
+		}
+		cols = append(cols, &column{
+			name:        name,
+			index:       i,
+			kind:        f.Type.Kind(),
+			date:        f.Type == reflect.TypeOf(&time.Time{}),
+			categorical: slices.Contains(categorical, name),
+			values:      map[string]int{},
+		})
+	}
+	return cols
+}
+
+// addRow counts one model's values into its columns.
+func addRow(cols []*column, model any) {
+	v := reflect.ValueOf(model)
+	for _, c := range cols {
+		f := v.Field(c.index)
+		switch {
+		case c.date:
+			if f.IsNil() {
+				c.missing++
+				continue
+			}
+			t := f.Interface().(*time.Time)
+			if c.min == nil || t.Before(*c.min) {
+				c.min = t
+			}
+			if c.max == nil || t.After(*c.max) {
+				c.max = t
+			}
+			c.values[formatDate(t)]++
+		case c.kind == reflect.String:
+			s := strings.TrimSpace(f.String())
+			switch {
+			case s == "":
+				c.missing++
+			case strings.EqualFold(s, "unknown"):
+				c.unknown++
+			default:
+				c.values[s]++
+			}
+		case c.kind == reflect.Int:
+			if f.Int() == 0 && zeroIsMissing[c.name] {
+				c.missing++
+				continue
+			}
+			c.values[strconv.FormatInt(f.Int(), 10)]++
+		}
+	}
+}
+
+// summarise reports on columns that saw rows values.
+func summarise(cols []*column, rows, top int) ([]Column, []Category) {
+	var columns []Column
+	var categories []Category
+	for _, c := range cols {
+		col := Column{Name: c.name, Missing: c.missing, Unknown: c.unknown, Distinct: len(c.values)}
+		if rows > 0 {
+			col.MissingRate = float64(c.missing) / float64(rows)
+			col.UnknownRate = float64(c.unknown) / float64(rows)
+		}
+		if c.date && c.min != nil {
+			col.Min, col.Max = formatDate(c.min), formatDate(c.max)
+		}
+		columns = append(columns, col)
+
+		if !c.categorical {
+			continue
+		}
+		counts := make([]Count, 0, len(c.values))
+		for value, n := range c.values {
+			counts = append(counts, Count{Value: value, Count: n})
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			return counts[i].Count > counts[j].Count || (counts[i].Count == counts[j].Count && counts[i].Value < counts[j].Value)
+		})
+		if len(counts) > top {
+			counts = counts[:top]
+		}
+		categories = append(categories, Category{Column: c.name, Distinct: len(c.values), Top: counts})
+	}
+	return columns, categories
+}
@@ -0,0 +1,124 @@
+package dq
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bbrombacher/animals/ingest"
+	"github.com/bbrombacher/animals/repository"
+	"github.com/bbrombacher/animals/synth"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/golden.md")
+
+var now = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func date(year int, month time.Month, day int) *time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	return &t
+}
+
+// TestGolden pins the report on a small synthetic file, malformed rows and
+// unknowns included. Run with -update to accept a change.
+func TestGolden(t *testing.T) {
+	var data bytes.Buffer
+	if _, err := synth.Generate(&data, synth.Options{Rows: 60, Seed: 42, RepeatRate: 0.3, MissingRate: 0.1, MalformedRate: 0.1}); err != nil {
+		t.Fatal(err)
+	}
+	src, err := ingest.NewCSVSource(&data, "synthetic.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProfiler("", Options{Now: now, Top: 3, Examples: 2})
+	if _, err := ingest.Run(context.Background(), src, ingest.Sonoma, p); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, p.Report()); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile("testdata/golden.md", buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile("testdata/golden.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("report differs from testdata/golden.md; run go test ./dq -update if that's intended")
+	}
+}
+
+func TestChecks(t *testing.T) {
+	p := NewProfiler("test", Options{Now: now, Top: 5, Examples: 5})
+	p.AddAnimal(repository.Animal{ShelterID: "sonoma", ID: "A1", AnimalName: "unknown", DateOfBirth: date(2030, 1, 1)})
+	p.AddAnimal(repository.Animal{ShelterID: "sonoma", ID: "A2", AnimalName: "Mochi"})
+	// consistent
+	p.AddIntake(repository.Intake{ShelterID: "sonoma", AnimalID: "A1", KennelNumber: "K1", ImpoundNumber: "I1",
+		IntakeDate: date(2023, 12, 2), OutcomeDate: date(2023, 12, 30), DaysInShelter: 28})
+	// across a daylight saving change, and one day off
+	p.AddIntake(repository.Intake{ShelterID: "sonoma", AnimalID: "A2", KennelNumber: "K2", ImpoundNumber: "I2",
+		IntakeDate: date(2024, 3, 1), OutcomeDate: date(2024, 3, 31), DaysInShelter: 31})
+	// outcome first, and repeated
+	backwards := repository.Intake{ShelterID: "sonoma", AnimalID: "A2", KennelNumber: "K3", ImpoundNumber: "I3",
+		IntakeDate: date(2024, 5, 2), OutcomeDate: date(2024, 5, 1), DaysInShelter: -1}
+	p.AddIntake(backwards)
+	p.AddIntake(backwards)
+	// no animal, and in the future
+	p.AddIntake(repository.Intake{ShelterID: "sonoma", AnimalID: "A3", KennelNumber: "K4", ImpoundNumber: "I4",
+		IntakeDate: date(2026, 1, 1)})
+
+	r := p.Report()
+	want := map[string]struct{ checked, failed int }{
+		CheckOutcomeBeforeIntake: {4, 2},
+		CheckBirthInFuture:       {2, 1},
+		CheckIntakeInFuture:      {5, 1},
+		CheckDaysInShelter:       {4, 1},
+		CheckOrphanIntake:        {3, 1},
+		CheckDuplicateIntake:     {5, 1},
+	}
+	for _, c := range r.Checks {
+		if w := want[c.Name]; c.Checked != w.checked || c.Failed != w.failed || len(c.Examples) != w.failed {
+			t.Errorf("%s: got %d checked, %d failed, examples %q, want %d and %d", c.Name, c.Checked, c.Failed, c.Examples, w.checked, w.failed)
+		}
+	}
+	if got := r.Checks[3].Examples; len(got) == 1 && !strings.HasPrefix(got[0], "A2 K2 I2: 31 days in shelter, 30 between") {
+		t.Errorf("got days in shelter example %q", got[0])
+	}
+
+	if got := r.AnimalColumns[2]; got.Name != "animal_name" || got.Unknown != 1 || got.UnknownRate != 0.5 {
+		t.Errorf("got animal_name %+v", got)
+	}
+	if got := r.IntakeColumns[5]; got.Name != "outcome_date" || got.Missing != 1 || got.Min != "2023-12-30" || got.Max != "2024-05-01" {
+		t.Errorf("got outcome_date %+v", got)
+	}
+
+	// a second report counts the same
+	if again := p.Report(); again.Checks[1].Checked != 2 || again.Checks[4].Failed != 1 {
+		t.Errorf("got checks %+v on a second report", again.Checks)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	p := NewProfiler("<export>", Options{Now: now, Top: 5})
+	p.AddAnimal(repository.Animal{ShelterID: "sonoma", ID: "A1", Breed: "<b>BEAGLE</b>"})
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, p.Report()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "&lt;b&gt;BEAGLE&lt;/b&gt;") || !strings.Contains(out, "<code>&lt;export&gt;</code>") {
+		t.Errorf("values aren't escaped:\n%s", out)
+	}
+	if strings.Contains(out, "<b>BEAGLE") {
+		t.Error("got an unescaped value")
+	}
+}
@@ -0,0 +1,218 @@
+package dq
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Report is the outcome of profiling a source.
+type Report struct {
+	Source      string
+	GeneratedAt time.Time
+	// Records and Malformed count the source's records when it was read
+	// with ingest.Run; both are 0 for the loaded tables.
+	Records   int
+	Malformed int
+	Animals   int
+	Intakes   int
+
+	AnimalColumns    []Column
+	IntakeColumns    []Column
+	AnimalCategories []Category
+	IntakeCategories []Category
+	Checks           []Check
+}
+
+// Column is how complete one column is. Rates are of the animals or intakes
+// the column belongs to.
+type Column struct {
+	Name string
+	// Missing counts empty values, and dates that didn't parse.
+	Missing     int
+	MissingRate float64
+	// Unknown counts values of "unknown", which exports use for missing
+	// text as often as an empty field.
+	Unknown     int
+	UnknownRate float64
+	Distinct    int
+	// Min and Max are the range of a date column.
+	Min, Max string
+}
+
+// Category lists the commonest values of a categorical column.
+type Category struct {
+	Column   string
+	Distinct int
+	Top      []Count
+}
+
+type Count struct {
+	Value string
+	Count int
+}
+
+// Check is a rule rows should follow and how many broke it.
+type Check struct {
+	Name        string
+	Description string
+	Checked     int
+	Failed      int
+	Examples    []string
+}
+
+// count records a row the check looked at, keeping up to max examples of
+// rows that failed.
+func (c *Check) count(failed bool, example string, max int) {
+	c.Checked++
+	if !failed {
+		return
+	}
+	c.Failed++
+	if len(c.Examples) < max {
+		c.Examples = append(c.Examples, example)
+	}
+}
+
+// Checks run on every report.
+const (
+	CheckOutcomeBeforeIntake = "outcome before intake"
+	CheckBirthInFuture       = "born in the future"
+	CheckIntakeInFuture      = "intake in the future"
+	CheckDaysInShelter       = "days in shelter"
+	CheckOrphanIntake        = "intake without animal"
+	CheckDuplicateIntake     = "duplicate intake"
+)
+
+// checks are reported in this order. Intake checks run as intakes are
+// added, the others once all animals are in.
+var checks = []struct {
+	Check
+	intake bool
+}{
+	{Check: Check{Name: CheckOutcomeBeforeIntake, Description: "Intakes with both dates whose outcome_date is before intake_date."}, intake: true},
+	{Check: Check{Name: CheckBirthInFuture, Description: "Animals whose date_of_birth is after the report was made."}},
+	{Check: Check{Name: CheckIntakeInFuture, Description: "Intakes whose intake_date is after the report was made."}, intake: true},
+	{Check: Check{Name: CheckDaysInShelter, Description: "Intakes with both dates whose days_in_shelter isn't the number of days between them."}, intake: true},
+	{Check: Check{Name: CheckOrphanIntake, Description: "Animals with intakes that have no row in animals; one example intake each."}},
+	{Check: Check{Name: CheckDuplicateIntake, Description: "Intakes repeating an earlier one's shelter, animal, kennel and impound number."}, intake: true},
+}
+
+var funcs = map[string]any{
+	"percent": func(rate float64) string {
+		return fmt.Sprintf("%.1f%%", rate*100)
+	},
+	"date": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+	// cell escapes a value for a Markdown table
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+	},
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(funcs).Parse(`# Data quality report
+
+Source: ` + "`{{.Source}}`" + `, generated {{date .GeneratedAt}}.
+
+{{if .Records}}{{.Records}} records, {{.Malformed}} malformed and skipped. {{end}}{{.Animals}} animals, {{.Intakes}} intakes.
+
+## Checks
+
+| Check | Checked | Failed | Description |
+| --- | ---: | ---: | --- |
+{{range .Checks}}| {{.Name}} | {{.Checked}} | {{.Failed}} | {{.Description}} |
+{{end}}{{range .Checks}}{{if .Examples}}
+Examples of **{{.Name}}**:
+{{range .Examples}}
+- {{.}}{{end}}
+{{end}}{{end}}
+{{define "columns"}}| Column | Missing | Unknown | Distinct | Range |
+| --- | ---: | ---: | ---: | --- |
+{{range .}}| {{.Name}} | {{.Missing}} ({{percent .MissingRate}}) | {{.Unknown}} ({{percent .UnknownRate}}) | {{.Distinct}} | {{if .Min}}{{.Min}} to {{.Max}}{{end}} |
+{{end}}{{end}}{{define "categories"}}{{range .}}
+### {{.Column}}
+
+{{.Distinct}} distinct values.
+
+| Value | Count |
+| --- | ---: |
+{{range .Top}}| {{cell .Value}} | {{.Count}} |
+{{end}}{{end}}{{end}}## Animal columns
+
+{{template "columns" .AnimalColumns}}
+## Intake columns
+
+{{template "columns" .IntakeColumns}}
+## Animal categories
+{{template "categories" .AnimalCategories}}
+## Intake categories
+{{template "categories" .IntakeCategories}}`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Data quality report</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; }
+td.n { text-align: right; }
+tr.failed { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Data quality report</h1>
+<p>Source: <code>{{.Source}}</code>, generated {{date .GeneratedAt}}.</p>
+<p>{{if .Records}}{{.Records}} records, {{.Malformed}} malformed and skipped. {{end}}{{.Animals}} animals, {{.Intakes}} intakes.</p>
+
+<h2>Checks</h2>
+<table>
+<tr><th>Check</th><th>Checked</th><th>Failed</th><th>Description</th></tr>
+{{range .Checks}}<tr{{if .Failed}} class="failed"{{end}}><td>{{.Name}}</td><td class="n">{{.Checked}}</td><td class="n">{{.Failed}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{range .Checks}}{{if .Examples}}<p>Examples of <b>{{.Name}}</b>:</p>
+<ul>
+{{range .Examples}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{end}}
+{{define "columns"}}<table>
+<tr><th>Column</th><th>Missing</th><th>Unknown</th><th>Distinct</th><th>Range</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td class="n">{{.Missing}} ({{percent .MissingRate}})</td><td class="n">{{.Unknown}} ({{percent .UnknownRate}})</td><td class="n">{{.Distinct}}</td><td>{{if .Min}}{{.Min}} to {{.Max}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}{{define "categories"}}{{range .}}<h3>{{.Column}}</h3>
+<p>{{.Distinct}} distinct values.</p>
+<table>
+<tr><th>Value</th><th>Count</th></tr>
+{{range .Top}}<tr><td>{{.Value}}</td><td class="n">{{.Count}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}<h2>Animal columns</h2>
+{{template "columns" .AnimalColumns}}
+<h2>Intake columns</h2>
+{{template "columns" .IntakeColumns}}
+<h2>Animal categories</h2>
+{{template "categories" .AnimalCategories}}
+<h2>Intake categories</h2>
+{{template "categories" .IntakeCategories}}</body>
+</html>
+`))
+
+// Formats a report can be written in.
+var Formats = map[string]func(io.Writer, Report) error{
+	"markdown": WriteMarkdown,
+	"html":     WriteHTML,
+}
+
+// WriteMarkdown writes r as a Markdown document.
+func WriteMarkdown(w io.Writer, r Report) error {
+	return markdownTemplate.Execute(w, r)
+}
+
+// WriteHTML writes r as a standalone HTML page.
+func WriteHTML(w io.Writer, r Report) error {
+	return htmlTemplate.Execute(w, r)
+}
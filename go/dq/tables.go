@@ -0,0 +1,38 @@
+package dq
+
+import (
+	"context"
+
+	"github.com/bbrombacher/animals/repository"
+)
+
+// tablePage is how many intakes AddTables reads per query.
+const tablePage = 5000
+
+// AddTables profiles the loaded animals and intakes, as the API serves
+// them: rows the ETL soft deleted are left out.
+func (p *Profiler) AddTables(ctx context.Context, animals repository.AnimalRepository, intakes repository.IntakeRepository) error {
+	err := animals.Stream(ctx, repository.AnimalQuery{}, func(a repository.Animal) error {
+		p.AddAnimal(a)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	q := repository.IntakeQuery{Limit: tablePage}
+	for {
+		page, err := intakes.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, i := range page {
+			p.AddIntake(i)
+		}
+		if len(page) < tablePage {
+			return nil
+		}
+		last := page[len(page)-1].Key()
+		q.After = &last
+	}
+}
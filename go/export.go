@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bbrombacher/animals/logging"
+	"github.com/bbrombacher/animals/repository"
+)
+
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// negotiateFormat picks a list format from the Accept header. An explicit
+// format query parameter overrides it.
+func negotiateFormat(req *http.Request) string {
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return formatCSV
+		case "application/x-ndjson", "application/ndjson":
+			return formatNDJSON
+		case "application/json":
+			return formatJSON
+		}
+	}
+	return formatJSON
+}
+
+// rowWriter encodes animals one at a time for a streamed export.
+type rowWriter interface {
+	WriteRow(repository.Animal) error
+	Flush() error
+}
+
+var animalCSVHeader = []string{"id", "animal_name", "animal_type", "breed", "color", "sex", "animal_size", "date_of_birth", "shelter_id"}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func newCSVRowWriter(w io.Writer) (*csvRowWriter, error) {
+	cw := csv.NewWriter(w)
+	return &csvRowWriter{w: cw}, cw.Write(animalCSVHeader)
+}
+
+func (c *csvRowWriter) WriteRow(a repository.Animal) error {
+	dob := ""
+	if a.DateOfBirth != nil {
+		dob = a.DateOfBirth.Format("2006-01-02")
+	}
+	return c.w.Write([]string{a.ID, a.AnimalName, a.AnimalType, a.Breed, a.Color, a.Sex, a.AnimalSize, dob, a.ShelterID})
+}
+
+func (c *csvRowWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonRowWriter struct {
+	enc *json.Encoder
+}
+
+func (n ndjsonRowWriter) WriteRow(a repository.Animal) error {
+	return n.enc.Encode(a)
+}
+
+func (n ndjsonRowWriter) Flush() error {
+	return nil
+}
+
+// exportFlushRows is how many rows are written between flushes to the
+// client.
+const exportFlushRows = 500
+
+// exportAnimals streams every matching animal straight from the rows cursor
+// to the client, so memory use doesn't grow with the export size.
+func (a AnimalController) exportAnimals(w http.ResponseWriter, req *http.Request, params GetAnimalsParams) {
+	ctx := req.Context()
+
+	// exports can legitimately outlast the server's write timeout
+	rc := http.NewResponseController(w)
+
+	var out rowWriter
+	count := 0
+	// headers are only sent once the first row arrives, so a query that
+	// fails outright still gets a normal error response
+	start := func() error {
+		rc.SetWriteDeadline(time.Time{})
+		switch params.Format {
+		case formatCSV:
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="animals.csv"`)
+			csvOut, err := newCSVRowWriter(w)
+			out = csvOut
+			return err
+		default:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			out = ndjsonRowWriter{enc: json.NewEncoder(w)}
+			return nil
+		}
+	}
+
+	err := a.Animals.Stream(ctx, params.query(ctx), func(animal repository.Animal) error {
+		if out == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := out.WriteRow(animal); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushRows == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			rc.Flush()
+		}
+		return nil
+	})
+	if err != nil && out == nil {
+		writeError(w, req, http.StatusInternalServerError, "failed to get data", err)
+		return
+	}
+	if err == nil && out == nil {
+		err = start()
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+
+	if err != nil {
+		// the status line is already sent, so abort the connection to
+		// make sure the client doesn't mistake a partial export for a
+		// complete one
+		logging.FromContext(ctx).Error("export failed", "error", err, "rows", count)
+		panic(http.ErrAbortHandler)
+	}
+	logging.FromContext(ctx).Debug("export finished", "format", params.Format, "rows", count)
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/repository"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# shelter is needed when more than one shelter has an animal with the id.
+	animal(id: ID!, shelter: ID): Animal
+	animals(first: Int, after: String, filter: AnimalFilter): AnimalConnection!
+	intakes(first: Int, after: String, filter: IntakeFilter): IntakeConnection!
+	stats(filter: IntakeFilter): Stats!
+}
+
+input AnimalFilter {
+	shelter: ID
+	animalType: String
+	breed: String
+	color: String
+	sex: String
+	animalSize: String
+}
+
+# Dates are YYYY-MM-DD.
+input IntakeFilter {
+	shelter: ID
+	animalId: ID
+	intakeType: String
+	outcomeType: String
+	intakeFrom: String
+	intakeTo: String
+	inShelter: Boolean
+}
+
+type Animal {
+	shelterId: ID!
+	id: ID!
+	name: String!
+	animalType: String!
+	breed: String!
+	color: String!
+	sex: String!
+	animalSize: String!
+	dateOfBirth: String
+	intakes: [Intake!]!
+}
+
+type Intake {
+	shelterId: ID!
+	impoundNumber: String!
+	kennelNumber: String!
+	animalId: ID!
+	intakeDate: String
+	outcomeDate: String
+	daysInShelter: Int!
+	intakeType: String!
+	intakeSubtype: String!
+	outcomeType: String!
+	outcomeSubtype: String!
+	intakeCondition: String!
+	outcomeCondition: String!
+	# null for public callers
+	intakeJurisdiction: String
+	# null for public callers
+	outcomeJurisdiction: String
+	# null for public callers
+	location: String
+	animalCount: Int!
+	# null for public callers
+	zipCode: Int
+	animal: Animal
+}
+
+type PageInfo {
+	hasNextPage: Boolean!
+	endCursor: String
+}
+
+type AnimalConnection {
+	edges: [AnimalEdge!]!
+	pageInfo: PageInfo!
+}
+
+type AnimalEdge {
+	cursor: String!
+	node: Animal!
+}
+
+type IntakeConnection {
+	edges: [IntakeEdge!]!
+	pageInfo: PageInfo!
+}
+
+type IntakeEdge {
+	cursor: String!
+	node: Intake!
+}
+
+type Stats {
+	animals: Int!
+	intakes: Int!
+	averageDaysInShelter: Float
+	byIntakeType: [ValueCount!]!
+	byOutcomeType: [ValueCount!]!
+}
+
+type ValueCount {
+	value: String!
+	count: Int!
+}
+`
+
+const (
+	graphqlDefaultPage = 20
+	graphqlMaxDepth    = 8
+	// graphqlMaxNodes caps the animals and intakes one request resolves.
+	// A connection counts its whole page before querying, so aliases and
+	// nested lists can't multiply pages into an unbounded response.
+	graphqlMaxNodes = 5000
+)
+
+var errTooExpensive = fmt.Errorf("query resolves more than %d animals and intakes, ask for smaller pages or fewer nested lists", graphqlMaxNodes)
+
+// GraphQL serves /graphql over animals and their intakes.
+type GraphQL struct {
+	Animals repository.AnimalRepository
+	Intakes repository.IntakeRepository
+
+	handler *relay.Handler
+}
+
+func NewGraphQL(animals repository.AnimalRepository, intakes repository.IntakeRepository) GraphQL {
+	schema := graphql.MustParseSchema(graphqlSchema, &queryResolver{animals: animals, intakes: intakes},
+		graphql.MaxDepth(graphqlMaxDepth),
+	)
+	return GraphQL{Animals: animals, Intakes: intakes, handler: &relay.Handler{Schema: schema}}
+}
+
+// ServeHTTP gives every request its own batch loaders, so related rows are
+// fetched once per page rather than once per parent.
+func (g GraphQL) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "graphql requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := withLoaders(req.Context(), newLoaders(g.Animals, g.Intakes))
+	g.handler.ServeHTTP(w, req.WithContext(ctx))
+}
+
+type queryResolver struct {
+	animals repository.AnimalRepository
+	intakes repository.IntakeRepository
+}
+
+type animalFilter struct {
+	Shelter    *graphql.ID
+	AnimalType *string
+	Breed      *string
+	Color      *string
+	Sex        *string
+	AnimalSize *string
+}
+
+func (f *animalFilter) query() repository.AnimalQuery {
+	if f == nil {
+		return repository.AnimalQuery{}
+	}
+	return repository.AnimalQuery{
+		ShelterID:  derefID(f.Shelter),
+		AnimalType: deref(f.AnimalType),
+		Breed:      deref(f.Breed),
+		Color:      deref(f.Color),
+		Sex:        deref(f.Sex),
+		AnimalSize: deref(f.AnimalSize),
+	}
+}
+
+type intakeFilter struct {
+	Shelter     *graphql.ID
+	AnimalID    *graphql.ID
+	IntakeType  *string
+	OutcomeType *string
+	IntakeFrom  *string
+	IntakeTo    *string
+	InShelter   *bool
+}
+
+func (f *intakeFilter) query() (repository.IntakeQuery, error) {
+	q := repository.IntakeQuery{}
+	if f == nil {
+		return q, nil
+	}
+	q.ShelterID = derefID(f.Shelter)
+	if f.AnimalID != nil {
+		q.AnimalIDs = []string{string(*f.AnimalID)}
+	}
+	q.IntakeType = deref(f.IntakeType)
+	q.OutcomeType = deref(f.OutcomeType)
+	q.InShelter = f.InShelter
+	if f.IntakeFrom != nil {
+		from, err := time.Parse("2006-01-02", *f.IntakeFrom)
+		if err != nil {
+			return q, fmt.Errorf("intakeFrom must be YYYY-MM-DD")
+		}
+		q.IntakeFrom = &from
+	}
+	if f.IntakeTo != nil {
+		to, err := time.Parse("2006-01-02", *f.IntakeTo)
+		if err != nil {
+			return q, fmt.Errorf("intakeTo must be YYYY-MM-DD")
+		}
+		to = to.AddDate(0, 0, 1)
+		q.IntakeTo = &to
+	}
+	return q, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefID(id *graphql.ID) string {
+	if id == nil {
+		return ""
+	}
+	return string(*id)
+}
+
+type pageArgs struct {
+	First *int32
+	After *string
+}
+
+func (p pageArgs) limit() (int, error) {
+	if p.First == nil {
+		return graphqlDefaultPage, nil
+	}
+	if *p.First < 1 || *p.First > maxLimit {
+		return 0, fmt.Errorf("first must be between 1 and %d", maxLimit)
+	}
+	return int(*p.First), nil
+}
+
+func (q *queryResolver) Animal(ctx context.Context, args struct {
+	ID      graphql.ID
+	Shelter *graphql.ID
+}) (*animalResolver, error) {
+	if err := loadersFrom(ctx).spend(1); err != nil {
+		return nil, err
+	}
+	animal, err := q.animals.Get(ctx, derefID(args.Shelter), string(args.ID), adoptableOnly(ctx))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if errors.Is(err, repository.ErrAmbiguous) {
+		return nil, fmt.Errorf("animal %s matches more than one shelter, pass shelter", args.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &animalResolver{a: animal}, nil
+}
+
+func (q *queryResolver) Animals(ctx context.Context, args struct {
+	pageArgs
+	Filter *animalFilter
+}) (*animalConnection, error) {
+	limit, err := args.limit()
+	if err != nil {
+		return nil, err
+	}
+	if err := loadersFrom(ctx).spend(limit); err != nil {
+		return nil, err
+	}
+
+	rows, more, err := animalPage(ctx, q.animals, args.Filter.query(), args.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &animalConnection{page: pageInfo{HasNextPage: more}}
+	keys := make([]repository.AnimalKey, 0, len(rows))
+	for _, row := range rows {
+		cursor := animalCursor(row)
+		conn.edges = append(conn.edges, &animalEdge{cursor: cursor, node: &animalResolver{a: row}})
+		conn.page.EndCursor = &cursor
+		keys = append(keys, row.Key())
+	}
+	loadersFrom(ctx).intakes.prime(keys...)
+	return conn, nil
+}
+
+func (q *queryResolver) Intakes(ctx context.Context, args struct {
+	pageArgs
+	Filter *intakeFilter
+}) (*intakeConnection, error) {
+	limit, err := args.limit()
+	if err != nil {
+		return nil, err
+	}
+	if err := loadersFrom(ctx).spend(limit); err != nil {
+		return nil, err
+	}
+
+	query, err := args.Filter.query()
+	if err != nil {
+		return nil, err
+	}
+	rows, more, err := intakePage(ctx, q.intakes, query, args.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &intakeConnection{page: pageInfo{HasNextPage: more}}
+	keys := make([]repository.AnimalKey, 0, len(rows))
+	for _, row := range rows {
+		cursor := intakeCursor(row)
+		conn.edges = append(conn.edges, &intakeEdge{cursor: cursor, node: &intakeResolver{i: row}})
+		conn.page.EndCursor = &cursor
+		keys = append(keys, row.AnimalKey())
+	}
+	loadersFrom(ctx).animals.prime(keys...)
+	return conn, nil
+}
+
+func (q *queryResolver) Stats(ctx context.Context, args struct{ Filter *intakeFilter }) (*statsResolver, error) {
+	query, err := args.Filter.query()
+	if err != nil {
+		return nil, err
+	}
+	query.AdoptableOnly = adoptableOnly(ctx)
+
+	stats, err := q.intakes.Stats(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &statsResolver{s: stats}, nil
+}
+
+func formatDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format("2006-01-02")
+	return &s
+}
+
+type pageInfo struct {
+	HasNextPage bool
+	EndCursor   *string
+}
+
+type pageInfoResolver struct {
+	p pageInfo
+}
+
+func (p pageInfoResolver) HasNextPage() bool  { return p.p.HasNextPage }
+func (p pageInfoResolver) EndCursor() *string { return p.p.EndCursor }
+
+type animalConnection struct {
+	edges []*animalEdge
+	page  pageInfo
+}
+
+func (c *animalConnection) Edges() []*animalEdge       { return c.edges }
+func (c *animalConnection) PageInfo() pageInfoResolver { return pageInfoResolver{c.page} }
+
+type animalEdge struct {
+	cursor string
+	node   *animalResolver
+}
+
+func (e *animalEdge) Cursor() string        { return e.cursor }
+func (e *animalEdge) Node() *animalResolver { return e.node }
+
+type intakeConnection struct {
+	edges []*intakeEdge
+	page  pageInfo
+}
+
+func (c *intakeConnection) Edges() []*intakeEdge       { return c.edges }
+func (c *intakeConnection) PageInfo() pageInfoResolver { return pageInfoResolver{c.page} }
+
+type intakeEdge struct {
+	cursor string
+	node   *intakeResolver
+}
+
+func (e *intakeEdge) Cursor() string        { return e.cursor }
+func (e *intakeEdge) Node() *intakeResolver { return e.node }
+
+type animalResolver struct {
+	a repository.Animal
+}
+
+func (r *animalResolver) ShelterID() graphql.ID { return graphql.ID(r.a.ShelterID) }
+func (r *animalResolver) ID() graphql.ID        { return graphql.ID(r.a.ID) }
+func (r *animalResolver) Name() string          { return r.a.AnimalName }
+func (r *animalResolver) AnimalType() string    { return r.a.AnimalType }
+func (r *animalResolver) Breed() string         { return r.a.Breed }
+func (r *animalResolver) Color() string         { return r.a.Color }
+func (r *animalResolver) Sex() string           { return r.a.Sex }
+func (r *animalResolver) AnimalSize() string    { return r.a.AnimalSize }
+func (r *animalResolver) DateOfBirth() *string  { return formatDate(r.a.DateOfBirth) }
+
+func (r *animalResolver) Intakes(ctx context.Context) ([]*intakeResolver, error) {
+	l := loadersFrom(ctx)
+	intakes, err := l.intakes.load(ctx, r.a.Key())
+	if err != nil {
+		return nil, err
+	}
+	if err := l.spend(len(intakes)); err != nil {
+		return nil, err
+	}
+	resolvers := make([]*intakeResolver, 0, len(intakes))
+	for _, i := range intakes {
+		resolvers = append(resolvers, &intakeResolver{i: i})
+	}
+	return resolvers, nil
+}
+
+type intakeResolver struct {
+	i repository.Intake
+}
+
+func (r *intakeResolver) ShelterID() graphql.ID    { return graphql.ID(r.i.ShelterID) }
+func (r *intakeResolver) ImpoundNumber() string    { return r.i.ImpoundNumber }
+func (r *intakeResolver) KennelNumber() string     { return r.i.KennelNumber }
+func (r *intakeResolver) AnimalID() graphql.ID     { return graphql.ID(r.i.AnimalID) }
+func (r *intakeResolver) IntakeDate() *string      { return formatDate(r.i.IntakeDate) }
+func (r *intakeResolver) OutcomeDate() *string     { return formatDate(r.i.OutcomeDate) }
+func (r *intakeResolver) DaysInShelter() int32     { return int32(r.i.DaysInShelter) }
+func (r *intakeResolver) IntakeType() string       { return r.i.IntakeType }
+func (r *intakeResolver) IntakeSubtype() string    { return r.i.IntakeSubtype }
+func (r *intakeResolver) OutcomeType() string      { return r.i.OutcomeType }
+func (r *intakeResolver) OutcomeSubtype() string   { return r.i.OutcomeSubtype }
+func (r *intakeResolver) IntakeCondition() string  { return r.i.IntakeCondition }
+func (r *intakeResolver) OutcomeCondition() string { return r.i.OutcomeCondition }
+func (r *intakeResolver) AnimalCount() int32       { return int32(r.i.AnimalCount) }
+
+func (r *intakeResolver) IntakeJurisdiction(ctx context.Context) *string {
+	return operational(ctx, r.i.IntakeJurisdiction)
+}
+
+func (r *intakeResolver) OutcomeJurisdiction(ctx context.Context) *string {
+	return operational(ctx, r.i.OutcomeJurisdiction)
+}
+
+func (r *intakeResolver) Location(ctx context.Context) *string {
+	return operational(ctx, r.i.Location)
+}
+
+func (r *intakeResolver) ZipCode(ctx context.Context) *int32 {
+	if !auth.FromContext(ctx).CanSeeOperationalData() {
+		return nil
+	}
+	zipCode := int32(r.i.ZipCode)
+	return &zipCode
+}
+
+func (r *intakeResolver) Animal(ctx context.Context) (*animalResolver, error) {
+	l := loadersFrom(ctx)
+	if err := l.spend(1); err != nil {
+		return nil, err
+	}
+	animal, err := l.animals.load(ctx, r.i.AnimalKey())
+	if err != nil || animal == nil {
+		return nil, err
+	}
+	return &animalResolver{a: *animal}, nil
+}
+
+// operational hides value from callers who may not see operational data.
+func operational(ctx context.Context, value string) *string {
+	if !auth.FromContext(ctx).CanSeeOperationalData() {
+		return nil
+	}
+	return &value
+}
+
+type statsResolver struct {
+	s repository.IntakeStats
+}
+
+func (s *statsResolver) Animals() int32                 { return int32(s.s.Animals) }
+func (s *statsResolver) Intakes() int32                 { return int32(s.s.Intakes) }
+func (s *statsResolver) AverageDaysInShelter() *float64 { return s.s.AverageDaysInShelter }
+func (s *statsResolver) ByIntakeType() []valueCount     { return valueCounts(s.s.ByIntakeType) }
+func (s *statsResolver) ByOutcomeType() []valueCount    { return valueCounts(s.s.ByOutcomeType) }
+
+type valueCount struct {
+	v repository.ValueCount
+}
+
+func (v valueCount) Value() string { return v.v.Value }
+func (v valueCount) Count() int32  { return int32(v.v.Count) }
+
+func valueCounts(counts []repository.ValueCount) []valueCount {
+	resolvers := make([]valueCount, 0, len(counts))
+	for _, c := range counts {
+		resolvers = append(resolvers, valueCount{v: c})
+	}
+	return resolvers
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bbrombacher/animals/repository"
+)
+
+// batchLoader fetches values by key in batches. Parents prime it with the
+// keys of every row on their page, and the first load fetches all primed
+// keys in one query, so resolving a relationship for N rows costs one query
+// instead of N. Loads of keys already being fetched wait for that batch
+// rather than fetching them again.
+type batchLoader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu       sync.Mutex
+	pending  map[K]bool
+	inflight map[K]*batch[K, V]
+	loaded   map[K]V
+}
+
+// batch is one fetch, done once values or err is set.
+type batch[K comparable, V any] struct {
+	done   chan struct{}
+	values map[K]V
+	err    error
+}
+
+func newBatchLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *batchLoader[K, V] {
+	return &batchLoader[K, V]{
+		fetch:    fetch,
+		pending:  map[K]bool{},
+		inflight: map[K]*batch[K, V]{},
+		loaded:   map[K]V{},
+	}
+}
+
+// prime queues keys for the next batch.
+func (b *batchLoader[K, V]) prime(keys ...K) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, key := range keys {
+		if _, ok := b.loaded[key]; ok {
+			continue
+		}
+		if _, ok := b.inflight[key]; !ok {
+			b.pending[key] = true
+		}
+	}
+}
+
+// load returns the value for key, fetching it along with every pending key
+// if it isn't loaded yet. Keys with no rows load as the zero value. The
+// lock isn't held during the fetch, so other fields keep resolving.
+func (b *batchLoader[K, V]) load(ctx context.Context, key K) (V, error) {
+	var zero V
+	b.mu.Lock()
+	if v, ok := b.loaded[key]; ok {
+		b.mu.Unlock()
+		return v, nil
+	}
+	if running, ok := b.inflight[key]; ok {
+		b.mu.Unlock()
+		select {
+		case <-running.done:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+		if running.err != nil {
+			return zero, running.err
+		}
+		return running.values[key], nil
+	}
+
+	b.pending[key] = true
+	keys := make([]K, 0, len(b.pending))
+	next := &batch[K, V]{done: make(chan struct{})}
+	for k := range b.pending {
+		keys = append(keys, k)
+		b.inflight[k] = next
+	}
+	clear(b.pending)
+	b.mu.Unlock()
+
+	next.values, next.err = b.fetch(ctx, keys)
+
+	b.mu.Lock()
+	for _, k := range keys {
+		// a failed batch leaves its keys to be fetched again
+		if next.err == nil {
+			b.loaded[k] = next.values[k]
+		}
+		delete(b.inflight, k)
+	}
+	b.mu.Unlock()
+	close(next.done)
+
+	if next.err != nil {
+		return zero, next.err
+	}
+	return next.values[key], nil
+}
+
+type loaders struct {
+	animals *batchLoader[repository.AnimalKey, *repository.Animal]
+	intakes *batchLoader[repository.AnimalKey, []repository.Intake]
+
+	// nodes counts the animals and intakes the request has resolved.
+	nodes atomic.Int64
+}
+
+// spend counts n more nodes against graphqlMaxNodes.
+func (l *loaders) spend(n int) error {
+	if l.nodes.Add(int64(n)) > graphqlMaxNodes {
+		return errTooExpensive
+	}
+	return nil
+}
+
+// Both loaders query once per shelter in the batch, which is usually one.
+func newLoaders(animals repository.AnimalRepository, intakes repository.IntakeRepository) *loaders {
+	return &loaders{
+		animals: newBatchLoader(func(ctx context.Context, keys []repository.AnimalKey) (map[repository.AnimalKey]*repository.Animal, error) {
+			byKey := make(map[repository.AnimalKey]*repository.Animal, len(keys))
+			for shelterID, ids := range idsByShelter(keys) {
+				rows, err := animals.List(ctx, repository.AnimalQuery{ShelterID: shelterID, IDs: ids, AdoptableOnly: adoptableOnly(ctx)})
+				if err != nil {
+					return nil, err
+				}
+				for i := range rows {
+					byKey[rows[i].Key()] = &rows[i]
+				}
+			}
+			return byKey, nil
+		}),
+		intakes: newBatchLoader(func(ctx context.Context, keys []repository.AnimalKey) (map[repository.AnimalKey][]repository.Intake, error) {
+			byAnimal := make(map[repository.AnimalKey][]repository.Intake, len(keys))
+			for shelterID, ids := range idsByShelter(keys) {
+				rows, err := intakes.List(ctx, repository.IntakeQuery{ShelterID: shelterID, AnimalIDs: ids, AdoptableOnly: adoptableOnly(ctx)})
+				if err != nil {
+					return nil, err
+				}
+				for _, row := range rows {
+					byAnimal[row.AnimalKey()] = append(byAnimal[row.AnimalKey()], row)
+				}
+			}
+			// an animal's intakes read best in the order they happened
+			for _, animalIntakes := range byAnimal {
+				sort.SliceStable(animalIntakes, func(i, j int) bool {
+					return intakeBefore(animalIntakes[i], animalIntakes[j])
+				})
+			}
+			return byAnimal, nil
+		}),
+	}
+}
+
+// idsByShelter groups animal keys by shelter.
+func idsByShelter(keys []repository.AnimalKey) map[string][]string {
+	ids := map[string][]string{}
+	for _, key := range keys {
+		ids[key.ShelterID] = append(ids[key.ShelterID], key.ID)
+	}
+	return ids
+}
+
+// intakeBefore orders intakes by date, undated ones last.
+func intakeBefore(a, b repository.Intake) bool {
+	if a.IntakeDate == nil || b.IntakeDate == nil {
+		return a.IntakeDate != nil && b.IntakeDate == nil
+	}
+	return a.IntakeDate.Before(*b.IntakeDate)
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
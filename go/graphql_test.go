@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/repository"
+	"github.com/bbrombacher/animals/repository/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+var biscuitIntake = repository.Intake{ShelterID: "sonoma", AnimalID: "A1", KennelNumber: "DS01", ImpoundNumber: "K1",
+	IntakeJurisdiction: "SANTA ROSA", OutcomeJurisdiction: "SANTA ROSA", Location: "95404(38.45, -122.70)", ZipCode: 95404}
+
+func TestGraphQLHidesOperationalFields(t *testing.T) {
+	cases := []struct {
+		name      string
+		principal auth.Principal
+		want      string
+	}{
+		{name: "public", principal: auth.Anonymous,
+			want: `{"zipCode":null,"location":null,"intakeJurisdiction":null}`},
+		{name: "volunteer", principal: auth.Principal{Subject: "v", Role: auth.RoleVolunteer},
+			want: `{"zipCode":95404,"location":"95404(38.45, -122.70)","intakeJurisdiction":"SANTA ROSA"}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			intakes := mocks.NewMockIntakeRepository(gomock.NewController(t))
+			intakes.EXPECT().List(gomock.Any(), gomock.Any()).Return([]repository.Intake{biscuitIntake}, nil)
+			handler := NewGraphQL(nil, intakes)
+
+			body := `{"query":"{ intakes(first: 1) { edges { node { zipCode location intakeJurisdiction } } } }"}`
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			var resp struct {
+				Data struct {
+					Intakes struct {
+						Edges []struct {
+							Node json.RawMessage
+						}
+					}
+				}
+				Errors []interface{}
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Errors) > 0 || len(resp.Data.Intakes.Edges) != 1 {
+				t.Fatalf("got %+v", resp)
+			}
+			if got := string(resp.Data.Intakes.Edges[0].Node); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLNodeLimit(t *testing.T) {
+	animals := mocks.NewMockAnimalRepository(gomock.NewController(t))
+	animals.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+	handler := NewGraphQL(animals, nil)
+
+	// six full pages are more than graphqlMaxNodes, whatever they return
+	var fields []string
+	for i := 0; i < 6; i++ {
+		fields = append(fields, fmt.Sprintf("a%d: animals(first: 1000) { edges { cursor } }", i))
+	}
+	query, err := json.Marshal(map[string]string{"query": "{ " + strings.Join(fields, " ") + " }"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(query)))
+
+	var resp struct {
+		Errors []struct {
+			Message string
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != errTooExpensive.Error() {
+		t.Errorf("got errors %+v, want one for the sixth page", resp.Errors)
+	}
+}
+
+func TestBatchLoaderReleasesLockWhileFetching(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var fetches atomic.Int32
+	loader := newBatchLoader(func(ctx context.Context, keys []string) (map[string]string, error) {
+		if fetches.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		values := map[string]string{}
+		for _, k := range keys {
+			values[k] = strings.ToUpper(k)
+		}
+		return values, nil
+	})
+	ctx := context.Background()
+	loader.prime("a", "b")
+
+	results := make(chan string, 2)
+	for _, key := range []string{"a", "b"} {
+		go func() {
+			if key == "b" {
+				<-started
+			}
+			v, err := loader.load(ctx, key)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- v
+		}()
+	}
+
+	// priming while the first batch is fetched would deadlock if the fetch
+	// held the lock
+	<-started
+	loader.prime("c")
+	close(release)
+	got := []string{<-results, <-results}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Errorf("got %v, want A and B", got)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("got %d fetches for one batch, want 1", n)
+	}
+
+	if v, err := loader.load(ctx, "c"); err != nil || v != "C" {
+		t.Errorf("load(c) = %q, %v, want C", v, err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("got %d fetches, want a second for the key primed during the first", n)
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/logging"
+	animalsv1 "github.com/bbrombacher/animals/proto/animals/v1"
+	"github.com/bbrombacher/animals/ratelimit"
+	"github.com/bbrombacher/animals/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	grpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "animals_grpc_requests_total",
+		Help: "gRPC calls served, by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "animals_grpc_request_duration_seconds",
+		Help:    "gRPC call latency, by method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+)
+
+// AnimalService implements animals.v1.AnimalService with the same paging
+// as the GraphQL API.
+type AnimalService struct {
+	animalsv1.UnimplementedAnimalServiceServer
+
+	Animals repository.AnimalRepository
+	Intakes repository.IntakeRepository
+}
+
+func (s AnimalService) ListAnimals(ctx context.Context, req *animalsv1.ListAnimalsRequest) (*animalsv1.ListAnimalsResponse, error) {
+	limit, err := grpcPageSize(req.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+
+	rows, more, err := animalPage(ctx, s.Animals, animalQuery(req.GetFilter()), optionalString(req.GetPageToken()), limit)
+	if err != nil {
+		return nil, grpcError(ctx, "failed to list animals", err)
+	}
+
+	resp := &animalsv1.ListAnimalsResponse{Animals: make([]*animalsv1.Animal, 0, len(rows))}
+	for _, row := range rows {
+		resp.Animals = append(resp.Animals, animalProto(row))
+	}
+	if more {
+		resp.NextPageToken = animalCursor(rows[len(rows)-1])
+	}
+	return resp, nil
+}
+
+func (s AnimalService) GetAnimal(ctx context.Context, req *animalsv1.GetAnimalRequest) (*animalsv1.Animal, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id must be set")
+	}
+
+	animal, err := s.Animals.Get(ctx, req.GetShelterId(), req.GetId(), adoptableOnly(ctx))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "animal not found")
+	}
+	if errors.Is(err, repository.ErrAmbiguous) {
+		return nil, status.Error(codes.FailedPrecondition, "animal id matches more than one shelter, set shelter_id")
+	}
+	if err != nil {
+		return nil, grpcError(ctx, "failed to get animal", err)
+	}
+	return animalProto(animal), nil
+}
+
+func (s AnimalService) ListIntakes(ctx context.Context, req *animalsv1.ListIntakesRequest) (*animalsv1.ListIntakesResponse, error) {
+	limit, err := grpcPageSize(req.GetPageSize())
+	if err != nil {
+		return nil, err
+	}
+
+	query := repository.IntakeQuery{
+		ShelterID:   req.GetShelterId(),
+		IntakeType:  req.GetIntakeType(),
+		OutcomeType: req.GetOutcomeType(),
+		InShelter:   req.InShelter,
+	}
+	if id := req.GetAnimalId(); id != "" {
+		query.AnimalIDs = []string{id}
+	}
+
+	rows, more, err := intakePage(ctx, s.Intakes, query, optionalString(req.GetPageToken()), limit)
+	if err != nil {
+		return nil, grpcError(ctx, "failed to list intakes", err)
+	}
+
+	operational := auth.FromContext(ctx).CanSeeOperationalData()
+	resp := &animalsv1.ListIntakesResponse{Intakes: make([]*animalsv1.Intake, 0, len(rows))}
+	for _, row := range rows {
+		resp.Intakes = append(resp.Intakes, intakeProto(row, operational))
+	}
+	if more {
+		resp.NextPageToken = intakeCursor(rows[len(rows)-1])
+	}
+	return resp, nil
+}
+
+// ExportAnimals sends matching animals as they are read, so like the CSV
+// and NDJSON exports it never holds the full result in memory.
+func (s AnimalService) ExportAnimals(req *animalsv1.ExportAnimalsRequest, stream grpc.ServerStreamingServer[animalsv1.Animal]) error {
+	ctx := stream.Context()
+
+	query := animalQuery(req.GetFilter())
+	query.AdoptableOnly = adoptableOnly(ctx)
+
+	count := 0
+	err := s.Animals.Stream(ctx, query, func(animal repository.Animal) error {
+		count++
+		return stream.Send(animalProto(animal))
+	})
+	if err != nil {
+		return grpcError(ctx, "failed to export animals", err)
+	}
+	logging.FromContext(ctx).Debug("export finished", "format", "grpc", "rows", count)
+	return nil
+}
+
+func animalProto(a repository.Animal) *animalsv1.Animal {
+	return &animalsv1.Animal{
+		ShelterId:   a.ShelterID,
+		Id:          a.ID,
+		Name:        a.AnimalName,
+		AnimalType:  a.AnimalType,
+		Breed:       a.Breed,
+		Color:       a.Color,
+		Sex:         a.Sex,
+		AnimalSize:  a.AnimalSize,
+		DateOfBirth: timestampProto(a.DateOfBirth),
+	}
+}
+
+// intakeProto converts i, leaving out jurisdictions, location and zip code
+// unless operational is set.
+func intakeProto(i repository.Intake, operational bool) *animalsv1.Intake {
+	intake := &animalsv1.Intake{
+		ShelterId:        i.ShelterID,
+		ImpoundNumber:    i.ImpoundNumber,
+		KennelNumber:     i.KennelNumber,
+		AnimalId:         i.AnimalID,
+		IntakeDate:       timestampProto(i.IntakeDate),
+		OutcomeDate:      timestampProto(i.OutcomeDate),
+		DaysInShelter:    int32(i.DaysInShelter),
+		IntakeType:       i.IntakeType,
+		IntakeSubtype:    i.IntakeSubtype,
+		OutcomeType:      i.OutcomeType,
+		OutcomeSubtype:   i.OutcomeSubtype,
+		IntakeCondition:  i.IntakeCondition,
+		OutcomeCondition: i.OutcomeCondition,
+		AnimalCount:      int32(i.AnimalCount),
+	}
+	if operational {
+		intake.IntakeJurisdiction = i.IntakeJurisdiction
+		intake.OutcomeJurisdiction = i.OutcomeJurisdiction
+		intake.Location = i.Location
+		intake.ZipCode = int32(i.ZipCode)
+	}
+	return intake
+}
+
+func timestampProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func animalQuery(f *animalsv1.AnimalFilter) repository.AnimalQuery {
+	return repository.AnimalQuery{
+		ShelterID:  f.GetShelterId(),
+		AnimalType: f.GetAnimalType(),
+		Breed:      f.GetBreed(),
+		Color:      f.GetColor(),
+		Sex:        f.GetSex(),
+		AnimalSize: f.GetAnimalSize(),
+	}
+}
+
+// optionalString treats proto3's empty page token as unset.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func grpcPageSize(size int32) (int, error) {
+	if size == 0 {
+		return defaultLimit, nil
+	}
+	if size < 1 || size > maxLimit {
+		return 0, status.Errorf(codes.InvalidArgument, "page_size must be between 1 and %d", maxLimit)
+	}
+	return int(size), nil
+}
+
+// grpcError logs err against the call and converts it to a status.
+func grpcError(ctx context.Context, msg string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, errInvalidCursor):
+		return status.Error(codes.InvalidArgument, "invalid page_token")
+	case errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	logging.FromContext(ctx).Error(msg, "error", err)
+	return status.Errorf(codes.Internal, "%s %v", msg, err)
+}
+
+// newGRPCServer returns a gRPC server for AnimalService. Calls are traced,
+// logged, measured, authenticated and rate limited like HTTP requests.
+func newGRPCServer(svc AnimalService, guard grpcGuard, withReflection bool) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(guard.unary),
+		grpc.ChainStreamInterceptor(guard.stream),
+	)
+	animalsv1.RegisterAnimalServiceServer(srv, svc)
+	if withReflection {
+		reflection.Register(srv)
+	}
+	return srv
+}
+
+// grpcGuard applies the HTTP API's request ID, access log, metrics, auth and
+// rate limiting to gRPC calls.
+type grpcGuard struct {
+	authenticators []auth.Authenticator
+	// limiter is nil when rate limiting is disabled.
+	limiter *rateLimiter
+}
+
+func (g grpcGuard) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, err := g.admit(ctx, info.FullMethod)
+	var resp any
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func (g grpcGuard) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, err := g.admit(ss.Context(), info.FullMethod)
+	if err == nil {
+		err = handler(srv, serverStream{ServerStream: ss, ctx: ctx})
+	}
+	logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// admit authenticates the call and takes a rate limit token for it,
+// returning the context the handler runs with.
+func (g grpcGuard) admit(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := ""
+	if ids := md.Get(requestIDHeader); len(ids) > 0 {
+		id = ids[0]
+	}
+	if !validRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	ctx = logging.WithRequestID(ctx, id)
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+
+	req := grpcHTTPRequest(ctx, method, md)
+	if g.limiter != nil && auth.HasCredentials(req) {
+		key := authRoute + "|" + clientIP(g.limiter.trustForwarded)(req)
+		if err := g.take(ctx, key, g.limiter.authLimit); err != nil {
+			return ctx, err
+		}
+	}
+	principal, err := auth.Authenticate(req, g.authenticators...)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		logging.FromContext(ctx).Warn("authentication failed", "error", err)
+		return ctx, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("authentication error", "error", err)
+		return ctx, status.Error(codes.Internal, "failed to authenticate")
+	}
+	ctx = auth.WithPrincipal(ctx, principal)
+
+	if g.limiter == nil {
+		return ctx, nil
+	}
+	key := method + "|" + clientKey(g.limiter.trustForwarded)(req.WithContext(ctx))
+	return ctx, g.take(ctx, key, g.limiter.limits.For(method))
+}
+
+// take takes a token from key's bucket, failing the call when there is
+// none. A store error lets the call through, as over HTTP.
+func (g grpcGuard) take(ctx context.Context, key string, limit ratelimit.Limit) error {
+	res, err := g.limiter.store.Take(ctx, key, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("rate limit check failed, allowing request", "error", err)
+		return nil
+	}
+	if !res.Allowed {
+		retryAfter := int(math.Ceil(res.RetryAfter.Seconds()))
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(retryAfter)))
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
+
+// grpcHTTPRequest presents a call's metadata and peer address as an HTTP
+// request, which is what the authenticators and client keys read.
+func grpcHTTPRequest(ctx context.Context, method string, md metadata.MD) *http.Request {
+	req := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: method},
+		Header: http.Header{},
+	}
+	for key, values := range md {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+	}
+	return req.WithContext(ctx)
+}
+
+// logCall writes the access log line and metrics for a finished call.
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	elapsed := time.Since(start)
+	grpcRequests.WithLabelValues(method, code.String()).Inc()
+	grpcDuration.WithLabelValues(method, code.String()).Observe(elapsed.Seconds())
+
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+	logging.FromContext(ctx).Log(ctx, level, "request",
+		"method", "grpc",
+		"route", method,
+		"code", code.String(),
+		"latency", elapsed,
+	)
+}
+
+// serverStream overrides a stream's context with one carrying the request
+// ID and principal.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
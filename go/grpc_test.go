@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bbrombacher/animals/auth"
+	animalsv1 "github.com/bbrombacher/animals/proto/animals/v1"
+	"github.com/bbrombacher/animals/repository"
+	"github.com/bbrombacher/animals/repository/mocks"
+	"go.uber.org/mock/gomock"
+)
+
+func TestListIntakesHidesOperationalFields(t *testing.T) {
+	cases := []struct {
+		name      string
+		principal auth.Principal
+		want      *animalsv1.Intake
+	}{
+		{name: "public", principal: auth.Anonymous,
+			want: &animalsv1.Intake{}},
+		{name: "volunteer", principal: auth.Principal{Subject: "v", Role: auth.RoleVolunteer},
+			want: &animalsv1.Intake{IntakeJurisdiction: "SANTA ROSA", OutcomeJurisdiction: "SANTA ROSA",
+				Location: "95404(38.45, -122.70)", ZipCode: 95404}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			intakes := mocks.NewMockIntakeRepository(gomock.NewController(t))
+			intakes.EXPECT().List(gomock.Any(), gomock.Any()).Return([]repository.Intake{biscuitIntake}, nil)
+			service := AnimalService{Intakes: intakes}
+
+			ctx := auth.WithPrincipal(context.Background(), tc.principal)
+			resp, err := service.ListIntakes(ctx, &animalsv1.ListIntakesRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Intakes) != 1 {
+				t.Fatalf("got %d intakes, want 1", len(resp.Intakes))
+			}
+			got := resp.Intakes[0]
+			if got.ZipCode != tc.want.ZipCode || got.Location != tc.want.Location ||
+				got.IntakeJurisdiction != tc.want.IntakeJurisdiction || got.OutcomeJurisdiction != tc.want.OutcomeJurisdiction {
+				t.Errorf("got zip code %d, location %q, jurisdictions %q and %q, want %d, %q, %q and %q",
+					got.ZipCode, got.Location, got.IntakeJurisdiction, got.OutcomeJurisdiction,
+					tc.want.ZipCode, tc.want.Location, tc.want.IntakeJurisdiction, tc.want.OutcomeJurisdiction)
+			}
+			if got.AnimalId != "A1" {
+				t.Errorf("got animal id %q", got.AnimalId)
+			}
+		})
+	}
+}
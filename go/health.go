@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbrombacher/animals/config"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+	statusSkipped     = "skipped"
+	statusDraining    = "draining"
+)
+
+// healthDB is the part of *sqlx.DB the readiness probe uses.
+type healthDB interface {
+	PingContext(ctx context.Context) error
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// Health serves the liveness and readiness probes.
+type Health struct {
+	DB          healthDB
+	PingTimeout time.Duration
+
+	// expectedVersion is the newest migration on disk, 0 when unknown.
+	expectedVersion int64
+	draining        *atomic.Bool
+}
+
+// ComponentStatus is the readiness result for one dependency. Detail is
+// only logged, since it can hold DB errors and versions.
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Detail string `json:"-"`
+}
+
+func NewHealth(db *sqlx.DB, cfg config.Config) Health {
+	version, err := latestMigrationVersion(cfg.MigrationsPath)
+	if err != nil {
+		slog.Warn("unable to find expected schema version, readiness will skip the check", "error", err)
+	}
+	return Health{
+		DB:              db,
+		PingTimeout:     cfg.DB.PingTimeout,
+		expectedVersion: version,
+		draining:        &atomic.Bool{},
+	}
+}
+
+// SetDraining makes Ready fail so traffic is routed away before shutdown.
+func (h Health) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Live reports that the process is up and serving; it never touches the DB.
+func (h Health) Live(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": statusOK,
+	})
+}
+
+// Ready reports whether this instance should receive traffic.
+func (h Health) Ready(w http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.PingTimeout)
+	defer cancel()
+
+	components := map[string]ComponentStatus{
+		"server":   {Status: statusOK},
+		"database": h.checkDatabase(ctx),
+		"schema":   h.checkSchema(ctx),
+	}
+	if h.draining.Load() {
+		components["server"] = ComponentStatus{Status: statusDraining}
+	}
+
+	status, code := statusOK, http.StatusOK
+	for name, c := range components {
+		if c.Status != statusOK && c.Status != statusSkipped {
+			status, code = statusUnavailable, http.StatusServiceUnavailable
+			slog.Warn("not ready", "component", name, "status", c.Status, "detail", c.Detail)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     status,
+		"components": components,
+	})
+}
+
+func (h Health) checkDatabase(ctx context.Context) ComponentStatus {
+	start := time.Now()
+	if err := h.DB.PingContext(ctx); err != nil {
+		return ComponentStatus{Status: statusUnavailable, Detail: err.Error()}
+	}
+	return ComponentStatus{Status: statusOK, Detail: fmt.Sprintf("ping %v", time.Since(start))}
+}
+
+// checkSchema compares the golang-migrate version recorded in the database
+// with the newest migration shipped alongside the binary.
+func (h Health) checkSchema(ctx context.Context) ComponentStatus {
+	if h.expectedVersion == 0 {
+		return ComponentStatus{Status: statusSkipped, Detail: "expected version unknown"}
+	}
+
+	var m schemaMigration
+	err := h.DB.GetContext(ctx, &m, "SELECT version, dirty FROM schema_migrations LIMIT 1")
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ComponentStatus{Status: statusUnavailable, Detail: "no migrations applied"}
+	case err != nil:
+		return ComponentStatus{Status: statusUnavailable, Detail: err.Error()}
+	case m.Dirty:
+		return ComponentStatus{Status: statusUnavailable, Detail: fmt.Sprintf("version %d is dirty", m.Version)}
+	case m.Version != h.expectedVersion:
+		return ComponentStatus{Status: statusUnavailable, Detail: fmt.Sprintf("version %d, expected %d", m.Version, h.expectedVersion)}
+	}
+	return ComponentStatus{Status: statusOK, Detail: fmt.Sprintf("version %d", m.Version)}
+}
+
+// schemaMigration is golang-migrate's record of the applied version.
+type schemaMigration struct {
+	Version int64 `db:"version"`
+	Dirty   bool  `db:"dirty"`
+}
+
+// latestMigrationVersion returns the highest version among the
+// golang-migrate files (<version>_<name>.up.sql) in dir.
+func latestMigrationVersion(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".up.sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(filepath.Base(name), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("no migrations found in %s", dir)
+	}
+	return latest, nil
+}
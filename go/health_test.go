@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDB answers the readiness probe's ping and schema version query.
+type fakeDB struct {
+	pingErr   error
+	migration schemaMigration
+	queryErr  error
+}
+
+func (f fakeDB) PingContext(ctx context.Context) error {
+	return f.pingErr
+}
+
+func (f fakeDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if f.queryErr != nil {
+		return f.queryErr
+	}
+	*dest.(*schemaMigration) = f.migration
+	return nil
+}
+
+func TestReady(t *testing.T) {
+	const expected = 2022010020001932
+	current := schemaMigration{Version: expected}
+
+	cases := []struct {
+		name       string
+		db         fakeDB
+		draining   bool
+		wantStatus int
+		wantBody   map[string]interface{}
+	}{
+		{name: "ready", db: fakeDB{migration: current}, wantStatus: http.StatusOK,
+			wantBody: readiness("ok", "ok", "ok", "ok")},
+		{name: "draining", db: fakeDB{migration: current}, draining: true, wantStatus: http.StatusServiceUnavailable,
+			wantBody: readiness("unavailable", "draining", "ok", "ok")},
+		{name: "old schema", db: fakeDB{migration: schemaMigration{Version: 2022010020001931}}, wantStatus: http.StatusServiceUnavailable,
+			wantBody: readiness("unavailable", "ok", "ok", "unavailable")},
+		{name: "dirty schema", db: fakeDB{migration: schemaMigration{Version: expected, Dirty: true}}, wantStatus: http.StatusServiceUnavailable,
+			wantBody: readiness("unavailable", "ok", "ok", "unavailable")},
+		{name: "no migrations", db: fakeDB{queryErr: sql.ErrNoRows}, wantStatus: http.StatusServiceUnavailable,
+			wantBody: readiness("unavailable", "ok", "ok", "unavailable")},
+		{name: "db down", db: fakeDB{pingErr: errors.New("dial tcp db:5432: connection refused"), queryErr: errors.New("connection refused")},
+			wantStatus: http.StatusServiceUnavailable, wantBody: readiness("unavailable", "ok", "unavailable", "unavailable")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := Health{DB: tc.db, PingTimeout: time.Second, expectedVersion: expected, draining: &atomic.Bool{}}
+			if tc.draining {
+				h.SetDraining()
+			}
+			rec := httptest.NewRecorder()
+			h.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tc.wantStatus)
+			}
+			// the body never carries errors or versions
+			if strings.Contains(rec.Body.String(), "connection refused") || strings.Contains(rec.Body.String(), "2022") {
+				t.Errorf("body leaks detail: %s", rec.Body)
+			}
+			body := map[string]interface{}{}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body, tc.wantBody) {
+				t.Errorf("got body %v, want %v", body, tc.wantBody)
+			}
+		})
+	}
+}
+
+// readiness builds the /readyz body for the given statuses.
+func readiness(status, server, database, schema string) map[string]interface{} {
+	component := func(status string) interface{} {
+		return map[string]interface{}{"status": status}
+	}
+	return map[string]interface{}{
+		"status": status,
+		"components": map[string]interface{}{
+			"server":   component(server),
+			"database": component(database),
+			"schema":   component(schema),
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CSVSource reads records from a CSV file with a header row. Rows with a
+// different number of fields than the header are malformed.
+type CSVSource struct {
+	name   string
+	r      *csv.Reader
+	header []string
+}
+
+var _ Source = (*CSVSource)(nil)
+
+// NewCSVSource reads the header from r. name identifies the file, e.g. its
+// path.
+func NewCSVSource(r io.Reader, name string) (*CSVSource, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	return &CSVSource{name: name, r: cr, header: header}, nil
+}
+
+func (s *CSVSource) Name() string {
+	return s.name
+}
+
+// Header returns the header row.
+func (s *CSVSource) Header() []string {
+	return s.header
+}
+
+func (s *CSVSource) Next(ctx context.Context) (Record, error) {
+	if err := ctx.Err(); err != nil {
+		return Record{}, err
+	}
+	fields, err := s.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Record{Line: parseErr.StartLine}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	line, _ := s.r.FieldPos(0)
+	rec := Record{Line: line, Fields: fields}
+	if len(fields) != len(s.header) {
+		return rec, fmt.Errorf("%w: %d fields, want %d", ErrMalformed, len(fields), len(s.header))
+	}
+	return rec, nil
+}
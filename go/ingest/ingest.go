@@ -0,0 +1,102 @@
+// Package ingest loads shelter data. A Source yields raw records, a
+// Transformer maps each one to an animal and its intake, and a Sink stores
+// them. cmd/etl wires a CSV file to Postgres; the server and tests can run
+// the same pipeline with other ends.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/bbrombacher/animals/repository"
+)
+
+// ErrMalformed marks a record that can't be loaded. Run skips these rather
+// than failing the whole load.
+var ErrMalformed = errors.New("malformed record")
+
+// Record is one raw row from a source.
+type Record struct {
+	// Line is the record's position in the source, for logs.
+	Line   int
+	Fields []string
+}
+
+// Row is a record mapped to the models it loads.
+type Row struct {
+	Animal repository.Animal
+	Intake repository.Intake
+}
+
+// Source yields the records of one load.
+type Source interface {
+	// Name identifies the source in etl_runs and logs.
+	Name() string
+	// Next returns the next record, or io.EOF when there are no more. An
+	// error wrapping ErrMalformed skips just that record.
+	Next(ctx context.Context) (Record, error)
+}
+
+// Transformer maps a record to the models it loads, returning an error
+// wrapping ErrMalformed when it can't.
+type Transformer interface {
+	Transform(rec Record) (Row, error)
+}
+
+// Sink stores rows. Begin is called once before the first Write and Finish
+// once after the last, unless the load fails.
+type Sink interface {
+	Begin(ctx context.Context, source string) error
+	Write(ctx context.Context, row Row) error
+	Finish(ctx context.Context, stats Stats) error
+}
+
+// Stats counts a load's records.
+type Stats struct {
+	// Read is every record the source returned, malformed or not.
+	Read    int
+	Loaded  int
+	Skipped int
+}
+
+// Run loads every record from src into sink. Malformed records are logged
+// and skipped; any other error stops the load and is returned.
+func Run(ctx context.Context, src Source, t Transformer, sink Sink) (Stats, error) {
+	stats := Stats{}
+	if err := sink.Begin(ctx, src.Name()); err != nil {
+		return stats, err
+	}
+
+	for {
+		rec, err := src.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		row := Row{}
+		if err == nil {
+			row, err = t.Transform(rec)
+		}
+		if errors.Is(err, ErrMalformed) {
+			slog.WarnContext(ctx, "skipping malformed record", "source", src.Name(), "line", rec.Line, "error", err)
+			stats.Read++
+			stats.Skipped++
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Read++
+
+		if err := sink.Write(ctx, row); err != nil {
+			return stats, err
+		}
+		stats.Loaded++
+	}
+
+	if err := sink.Finish(ctx, stats); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,304 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bbrombacher/animals/repository"
+	"github.com/bbrombacher/animals/synth"
+)
+
+// sonomaRecord returns a well-formed Sonoma record with fields overridden by
+// header name.
+func sonomaRecord(fields map[string]string) Record {
+	values := map[string]string{
+		"animal_id": "A1", "animal_name": "BISCUIT", "impound_number": "K24-000001", "kennel_number": "DS01",
+		"intake_date": "01/13/2024", "days_in_shelter": "12", "count": "1",
+	}
+	for k, v := range fields {
+		values[k] = v
+	}
+	rec := Record{Line: 2, Fields: make([]string, len(synth.Header))}
+	for i, name := range synth.Header {
+		rec.Fields[i] = values[name]
+	}
+	return rec
+}
+
+func TestSonomaProfile(t *testing.T) {
+	transformer := Sonoma
+	day := func(year int, month time.Month, d int) string {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.Local).Format(time.DateOnly)
+	}
+	formatDate := func(d *time.Time) string {
+		if d == nil {
+			return "nil"
+		}
+		return d.Format(time.DateOnly)
+	}
+
+	cases := []struct {
+		name   string
+		fields map[string]string
+		check  func(t *testing.T, row Row)
+	}{
+		{name: "dates are month first", fields: map[string]string{"date_of_birth": "04/01/2019", "outcome_date": "12/30/2024"}, check: func(t *testing.T, row Row) {
+			if got := formatDate(row.Animal.DateOfBirth); got != day(2019, 4, 1) {
+				t.Errorf("got date of birth %s", got)
+			}
+			if got := formatDate(row.Intake.IntakeDate); got != day(2024, 1, 13) {
+				t.Errorf("got intake date %s", got)
+			}
+			if got := formatDate(row.Intake.OutcomeDate); got != day(2024, 12, 30) {
+				t.Errorf("got outcome date %s", got)
+			}
+		}},
+		{name: "unknown dates", fields: map[string]string{"date_of_birth": "unknown"}, check: func(t *testing.T, row Row) {
+			if row.Animal.DateOfBirth != nil || row.Intake.OutcomeDate != nil {
+				t.Errorf("got dates %v and %v, want nil", row.Animal.DateOfBirth, row.Intake.OutcomeDate)
+			}
+		}},
+		{name: "thousands separator", fields: map[string]string{"days_in_shelter": "1,049"}, check: func(t *testing.T, row Row) {
+			if row.Intake.DaysInShelter != 1049 {
+				t.Errorf("got %d days", row.Intake.DaysInShelter)
+			}
+		}},
+		{name: "float zip code", fields: map[string]string{"zip_code": "95404.0"}, check: func(t *testing.T, row Row) {
+			if row.Intake.ZipCode != 95404 {
+				t.Errorf("got zip code %d", row.Intake.ZipCode)
+			}
+		}},
+		{name: "integer zip code", fields: map[string]string{"zip_code": "95472"}, check: func(t *testing.T, row Row) {
+			if row.Intake.ZipCode != 95472 {
+				t.Errorf("got zip code %d", row.Intake.ZipCode)
+			}
+		}},
+		{name: "columns", fields: map[string]string{"animal_type": "DOG", "intake_subtype": "FIELD", "outcome_jurisdiction": "COUNTY"}, check: func(t *testing.T, row Row) {
+			if row.Animal.ShelterID != "sonoma" || row.Intake.ShelterID != "sonoma" {
+				t.Errorf("got shelters %q and %q", row.Animal.ShelterID, row.Intake.ShelterID)
+			}
+			if row.Animal.ID != "A1" || row.Intake.AnimalID != "A1" || row.Animal.AnimalName != "BISCUIT" || row.Animal.AnimalType != "DOG" {
+				t.Errorf("got animal %+v", row.Animal)
+			}
+			if row.Intake.KennelNumber != "DS01" || row.Intake.IntakeSubtype != "FIELD" || row.Intake.OutcomeJurisdiction != "COUNTY" {
+				t.Errorf("got intake %+v", row.Intake)
+			}
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			row, err := transformer.Transform(sonomaRecord(tc.fields))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, row)
+		})
+	}
+
+	malformed := map[string]Record{
+		"count":     sonomaRecord(map[string]string{"count": "n/a"}),
+		"days":      sonomaRecord(map[string]string{"days_in_shelter": ""}),
+		"zip code":  sonomaRecord(map[string]string{"zip_code": "9540X"}),
+		"no id":     sonomaRecord(map[string]string{"animal_id": ""}),
+		"too short": {Line: 2, Fields: []string{"0", "BISCUIT"}},
+	}
+	for name, rec := range malformed {
+		t.Run("malformed "+name, func(t *testing.T) {
+			if _, err := transformer.Transform(rec); !errors.Is(err, ErrMalformed) {
+				t.Errorf("got error %v, want ErrMalformed", err)
+			}
+		})
+	}
+}
+
+func TestProfileAbsentColumns(t *testing.T) {
+	profile := Profile{
+		Shelter:    repository.Shelter{ID: "other"},
+		DateLayout: "2006-01-02",
+		Columns: Columns{
+			AnimalID: 0, AnimalName: 1, AnimalType: Absent, Breed: Absent, Color: Absent, Sex: Absent, AnimalSize: Absent, DateOfBirth: Absent,
+			ImpoundNumber: 2, KennelNumber: Absent, IntakeDate: 3, OutcomeDate: Absent, DaysInShelter: Absent,
+			IntakeType: 4, IntakeSubtype: Absent, OutcomeType: Absent, OutcomeSubtype: Absent, IntakeCondition: Absent,
+			OutcomeCondition: Absent, IntakeJurisdiction: Absent, OutcomeJurisdiction: Absent, ZipCode: Absent,
+			Location: Absent, AnimalCount: Absent,
+		},
+	}
+	row, err := profile.Transform(Record{Line: 2, Fields: []string{"A1", "BISCUIT", "I-1", "2024-01-13", "STRAY"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.Animal.ShelterID != "other" || row.Animal.ID != "A1" || row.Animal.AnimalType != "" || row.Intake.ImpoundNumber != "I-1" || row.Intake.IntakeType != "STRAY" {
+		t.Errorf("got row %+v", row)
+	}
+	if row.Intake.IntakeDate == nil || row.Intake.IntakeDate.Day() != 13 || row.Intake.AnimalCount != 1 || row.Intake.DaysInShelter != 0 {
+		t.Errorf("got intake %+v", row.Intake)
+	}
+}
+
+func TestLookupProfile(t *testing.T) {
+	if p, err := LookupProfile("sonoma"); err != nil || p.Shelter.ID != "sonoma" {
+		t.Errorf("got %+v, %v", p.Shelter, err)
+	}
+	if p, err := LookupProfile("sonoma-api"); err != nil || p.Shelter.ID != "sonoma" || len(p.Fields) != SonomaColumns.width() {
+		t.Errorf("got %+v, %v", p, err)
+	}
+	if _, err := LookupProfile("atlantis"); err == nil {
+		t.Error("got no error for an unknown profile")
+	}
+}
+
+// memorySink keeps what it's given.
+type memorySink struct {
+	source   string
+	rows     []Row
+	finished *Stats
+}
+
+func (s *memorySink) Begin(ctx context.Context, source string) error {
+	s.source = source
+	return nil
+}
+
+func (s *memorySink) Write(ctx context.Context, row Row) error {
+	s.rows = append(s.rows, row)
+	return nil
+}
+
+func (s *memorySink) Finish(ctx context.Context, stats Stats) error {
+	s.finished = &stats
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+	want, err := synth.Generate(&buf, synth.Options{Rows: 1000, Seed: 3, RepeatRate: 0.25, MissingRate: 0.05, MalformedRate: 0.05})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a stray quote is a csv parse error, which only skips its row
+	buf.WriteString("1000,\"BAD\"QUOTE\n")
+
+	src, err := NewCSVSource(&buf, "synthetic.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &memorySink{}
+	stats, err := Run(context.Background(), src, Sonoma, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Read != want.Rows+1 || stats.Skipped != want.Malformed+1 || stats.Loaded != want.Intakes {
+		t.Errorf("got %+v, want %d read, %d skipped and %d loaded", stats, want.Rows+1, want.Malformed+1, want.Intakes)
+	}
+	if sink.source != "synthetic.csv" || sink.finished == nil || *sink.finished != stats || len(sink.rows) != stats.Loaded {
+		t.Errorf("sink got source %q, %d rows and finish %v", sink.source, len(sink.rows), sink.finished)
+	}
+	animals := map[string]bool{}
+	for _, row := range sink.rows {
+		if !strings.HasPrefix(row.Animal.ID, "A") || row.Intake.AnimalID != row.Animal.ID {
+			t.Fatalf("got row %+v", row)
+		}
+		animals[row.Animal.ID] = true
+	}
+	if len(animals) != want.Animals {
+		t.Errorf("got %d animals, want %d", len(animals), want.Animals)
+	}
+}
+
+func TestRunStopsOnSinkError(t *testing.T) {
+	src, err := NewCSVSource(strings.NewReader(strings.Join(synth.Header, ",")+"\n"+strings.Join(sonomaRecord(nil).Fields, ",")+"\n"), "one.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := errors.New("disk full")
+	_, err = Run(context.Background(), src, Sonoma, &failingSink{err: failing})
+	if !errors.Is(err, failing) {
+		t.Errorf("got error %v, want %v", err, failing)
+	}
+}
+
+type failingSink struct {
+	memorySink
+	err error
+}
+
+func (s *failingSink) Write(ctx context.Context, row Row) error {
+	return s.err
+}
+
+func TestHashRow(t *testing.T) {
+	row, err := Sonoma.Transform(sonomaRecord(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the row index isn't part of the content
+	moved, err := Sonoma.Transform(sonomaRecord(map[string]string{"": "99"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := Sonoma.Transform(sonomaRecord(map[string]string{"outcome_type": "ADOPTION"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := func(row Row) string {
+		h, err := hashRow(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(h)
+	}
+	if hash(row) != hash(moved) {
+		t.Error("moving a row changed its hash")
+	}
+	if hash(row) == hash(changed) {
+		t.Error("changing a row kept its hash")
+	}
+}
+
+func TestResolveAnimal(t *testing.T) {
+	row := func(sex string, date *time.Time) Row {
+		return Row{
+			Animal: repository.Animal{ShelterID: "sonoma", ID: "A1", Sex: sex},
+			Intake: repository.Intake{ShelterID: "sonoma", AnimalID: "A1", IntakeDate: date},
+		}
+	}
+	day := func(d int) *time.Time {
+		t := time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	for _, tc := range []struct {
+		name        string
+		rows        []Row
+		changed     []bool
+		wantSex     string
+		wantChanged bool
+	}{
+		{"newest last", []Row{row("Female", day(1)), row("Spayed", day(4))}, []bool{false, true}, "Spayed", true},
+		{"newest first", []Row{row("Spayed", day(4)), row("Female", day(1))}, []bool{false, false}, "Spayed", false},
+		{"undated is oldest", []Row{row("Spayed", day(4)), row("Female", nil)}, []bool{false, true}, "Spayed", true},
+		{"same date, later row", []Row{row("Female", day(4)), row("Spayed", day(4))}, []bool{true, false}, "Spayed", true},
+		{"both undated, later row", []Row{row("Female", nil), row("Spayed", nil)}, []bool{false, false}, "Spayed", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Postgres{animals: map[repository.AnimalKey]*animalRow{}}
+			for i, r := range tc.rows {
+				p.resolveAnimal(r, tc.changed[i])
+			}
+			if len(p.animals) != 1 {
+				t.Fatalf("got %d animals, want 1", len(p.animals))
+			}
+			for _, a := range p.animals {
+				if a.animal.Sex != tc.wantSex || a.changed != tc.wantChanged {
+					t.Errorf("got %s changed %v, want %s changed %v", a.animal.Sex, a.changed, tc.wantSex, tc.wantChanged)
+				}
+			}
+		})
+	}
+}
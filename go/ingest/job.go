@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bbrombacher/animals/config"
+)
+
+// Job is one configured load: a CSV file, or a dataset downloaded first,
+// read with a profile into a sink. cmd/etl runs one job, the server's
+// scheduler one per scheduled load.
+type Job struct {
+	Profile Profile
+	// CSVPath is read unless Download is set.
+	CSVPath  string
+	Download *Socrata
+	Sink     Sink
+}
+
+// NewJob builds the job cfg describes, reading csvPath unless cfg has a
+// source URL. The caller sets its Sink.
+func NewJob(cfg config.ETL, csvPath string) (Job, error) {
+	profile, err := LookupProfile(cfg.Profile)
+	if err != nil {
+		return Job{}, err
+	}
+	job := Job{Profile: profile, CSVPath: csvPath}
+	if cfg.SourceURL != "" {
+		if len(profile.Fields) == 0 {
+			return Job{}, fmt.Errorf("profile %q has no API fields, use an -api profile with a source URL", cfg.Profile)
+		}
+		job.Download = &Socrata{
+			URL:      cfg.SourceURL,
+			Fields:   profile.Fields,
+			PageSize: cfg.PageSize,
+			Retries:  cfg.Retries,
+			Backoff:  cfg.RetryBackoff,
+			Archive:  cfg.Archive,
+		}
+		if cfg.RequestTimeout > 0 {
+			job.Download.Client = &http.Client{Timeout: cfg.RequestTimeout}
+		}
+	}
+	return job, nil
+}
+
+// Result is what a job did.
+type Result struct {
+	Stats Stats
+	// Snapshot is the download that was loaded, when the job has one.
+	Snapshot *Snapshot
+	// NotModified is set when the download found the dataset unchanged
+	// since the last load, in which case nothing was loaded.
+	NotModified bool
+}
+
+// Run downloads the job's dataset, if it has one, and loads it. A download
+// is only recorded as loaded, and so compared with by the next one, once the
+// load succeeds.
+func (j Job) Run(ctx context.Context) (Result, error) {
+	res := Result{}
+	path, name := j.CSVPath, j.CSVPath
+	if j.Download != nil {
+		snap, err := j.Download.Download(ctx)
+		if errors.Is(err, ErrNotModified) {
+			res.Snapshot, res.NotModified = &snap, true
+			return res, nil
+		}
+		if err != nil {
+			return res, fmt.Errorf("downloading %s: %w", j.Download.URL, err)
+		}
+		res.Snapshot = &snap
+		path, name = snap.Path, snap.URL
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	src, err := NewCSVSource(f, name)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", path, err)
+	}
+	res.Stats, err = Run(ctx, src, j.Profile, j.Sink)
+	if err != nil {
+		return res, err
+	}
+
+	if res.Snapshot != nil {
+		if err := j.Download.SetLatest(*res.Snapshot); err != nil {
+			return res, fmt.Errorf("recording snapshot: %w", err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,30 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
+
+// JSONSink writes each row to W as a line of JSON, e.g. to inspect what a
+// load would store. Finish flushes W if it is buffered.
+type JSONSink struct {
+	W io.Writer
+}
+
+var _ Sink = JSONSink{}
+
+func (s JSONSink) Begin(ctx context.Context, source string) error {
+	return nil
+}
+
+func (s JSONSink) Write(ctx context.Context, row Row) error {
+	return json.NewEncoder(s.W).Encode(row)
+}
+
+func (s JSONSink) Finish(ctx context.Context, stats Stats) error {
+	if f, ok := s.W.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,420 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bbrombacher/animals/repository"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Postgres loads rows into the animals and animal_intake tables and records
+// the load in etl_runs, which tells servers to drop cached responses once
+// it finishes.
+//
+// Loads are incremental: each row's content hash is compared with the one
+// stored by the shelter's previous load, and only new or changed rows are
+// written, in batches. The history triggers put each change down to the
+// load's etl_runs row. A load is one transaction, so readers never see part
+// of one and a failed load leaves nothing behind. Close must be called once
+// the load is done, whether or not it succeeded.
+type Postgres struct {
+	DB *sqlx.DB
+	// Shelter is the shelter the rows belong to, created if it is new.
+	Shelter repository.Shelter
+	// Watermark skips rows with an intake date before the latest one the
+	// previous load saw. Older rows that changed upstream are missed.
+	Watermark bool
+	// SoftDelete marks intakes that vanished from the source as deleted,
+	// and animals once all their intakes are. They are kept otherwise.
+	SoftDelete bool
+
+	runID     int64
+	tx        *sqlx.Tx
+	hashes    map[repository.IntakeKey]*rowHash
+	watermark *time.Time
+	latest    *time.Time
+	changes   Changes
+	// pending are the changed intakes not yet written, by key.
+	pending    []pendingRow
+	pendingKey map[repository.IntakeKey]int
+	// animals are the animals this load saw, each from its newest intake.
+	animals map[repository.AnimalKey]*animalRow
+}
+
+var _ Sink = (*Postgres)(nil)
+
+// Changes counts how a load's rows compare with the previous load's.
+type Changes struct {
+	New       int
+	Changed   int
+	Unchanged int
+	// Disappeared rows were in the previous load but not this one. Rows
+	// before the watermark aren't counted, as they weren't compared.
+	Disappeared     int
+	BeforeWatermark int
+}
+
+// rowHash is the stored hash of a row and whether this load has seen it.
+type rowHash struct {
+	hash       []byte
+	intakeDate *time.Time
+	seen       bool
+}
+
+// pendingRow is a changed intake and its hash, waiting for the next batch.
+type pendingRow struct {
+	intake repository.Intake
+	hash   []byte
+}
+
+// animalRow is an animal as its newest intake row has it, and whether any
+// of its rows changed, which is when it is written.
+type animalRow struct {
+	animal     repository.Animal
+	intakeDate *time.Time
+	changed    bool
+}
+
+// LockKey is the Postgres advisory lock a load holds for its transaction,
+// so only one load, from cmd/etl or a server's schedule, runs at a time.
+const LockKey = 2646257 // "animals" on a phone keypad
+
+// ErrLocked means another load held LockKey, so this one didn't start.
+var ErrLocked = errors.New("another load is running")
+
+// writeBatch is how many rows each insert writes. Intakes have 18 columns,
+// well inside Postgres's 65535 parameters per statement.
+const writeBatch = 500
+
+var (
+	upsertAnimal = upsert("animals", []string{"shelter_id", "id"},
+		[]string{"animal_name", "animal_type", "breed", "color", "sex", "animal_size", "date_of_birth"})
+	upsertIntake = upsert("animal_intake", []string{"shelter_id", "animal_id", "kennel_number", "impound_number"},
+		[]string{"intake_date", "outcome_date", "days_in_shelter", "intake_type", "intake_subtype", "outcome_type",
+			"outcome_subtype", "intake_condition", "outcome_condition", "intake_jurisdiction", "outcome_jurisdiction",
+			"location", "animal_count", "zip_code"})
+	upsertHash = `INSERT INTO etl_row_hashes
+		(shelter_id, animal_id, kennel_number, impound_number, hash, intake_date, etl_run_id)
+		VALUES (:shelter_id, :animal_id, :kennel_number, :impound_number, :hash, :intake_date, :etl_run_id)
+		ON CONFLICT (shelter_id, animal_id, kennel_number, impound_number)
+		DO UPDATE SET hash = EXCLUDED.hash, intake_date = EXCLUDED.intake_date, etl_run_id = EXCLUDED.etl_run_id`
+)
+
+// hashArg is a row of etl_row_hashes.
+type hashArg struct {
+	ShelterID     string     `db:"shelter_id"`
+	AnimalID      string     `db:"animal_id"`
+	KennelNumber  string     `db:"kennel_number"`
+	ImpoundNumber string     `db:"impound_number"`
+	Hash          []byte     `db:"hash"`
+	IntakeDate    *time.Time `db:"intake_date"`
+	ETLRunID      int64      `db:"etl_run_id"`
+}
+
+// upsert builds a named insert of a row keyed by key that, when the row
+// exists, updates the other columns if any of them differ and undoes a soft
+// delete. Skipping identical rows keeps them out of the history.
+func upsert(table string, key, columns []string) string {
+	all := append(append([]string{}, key...), columns...)
+	current := make([]string, len(columns))
+	excluded := make([]string, len(columns))
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		current[i] = table + "." + c
+		excluded[i] = "EXCLUDED." + c
+		set[i] = c + " = EXCLUDED." + c
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (:%s) ON CONFLICT (%s) DO UPDATE SET %s, deleted_at = NULL WHERE (%s) IS DISTINCT FROM (%s) OR %s.deleted_at IS NOT NULL",
+		table, strings.Join(all, ", "), strings.Join(all, ", :"), strings.Join(key, ", "),
+		strings.Join(set, ", "), strings.Join(current, ", "), strings.Join(excluded, ", "), table)
+}
+
+// hashRow returns the content hash of a row's mapped values, so a change in
+// the source's row order or formatting alone doesn't count as a change.
+func hashRow(row Row) ([]byte, error) {
+	b, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+func (p *Postgres) Begin(ctx context.Context, source string) error {
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	p.tx = tx
+	var locked bool
+	if err := p.tx.QueryRowxContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", LockKey).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return ErrLocked
+	}
+	// intakes are written before the animals they reference, which are
+	// only resolved once every row has been seen
+	if _, err := p.tx.ExecContext(ctx, "SET CONSTRAINTS fk_animal_id DEFERRED"); err != nil {
+		return err
+	}
+
+	if err := p.exec(ctx, `INSERT INTO shelters (id, name) VALUES (:id, :name)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name`, p.Shelter); err != nil {
+		return err
+	}
+	err = p.tx.QueryRowxContext(ctx, "INSERT INTO etl_runs (source, shelter_id) VALUES ($1, $2) RETURNING id", source, p.Shelter.ID).Scan(&p.runID)
+	if err != nil {
+		return err
+	}
+	_, err = p.tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", repository.ChangeSourceSetting, fmt.Sprintf("etl_run:%d", p.runID))
+	if err != nil {
+		return err
+	}
+
+	if p.Watermark {
+		err := p.tx.QueryRowxContext(ctx, `SELECT watermark FROM etl_runs
+			WHERE shelter_id = $1 AND finished_at IS NOT NULL AND watermark IS NOT NULL
+			ORDER BY id DESC LIMIT 1`, p.Shelter.ID).Scan(&p.watermark)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		p.latest = p.watermark
+	}
+	p.pendingKey = map[repository.IntakeKey]int{}
+	p.animals = map[repository.AnimalKey]*animalRow{}
+	return p.loadHashes(ctx)
+}
+
+// loadHashes reads the hashes stored by the shelter's previous loads.
+func (p *Postgres) loadHashes(ctx context.Context) error {
+	rows, err := p.tx.QueryxContext(ctx, `SELECT animal_id, kennel_number, impound_number, hash, intake_date
+		FROM etl_row_hashes WHERE shelter_id = $1`, p.Shelter.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	p.hashes = map[repository.IntakeKey]*rowHash{}
+	for rows.Next() {
+		key := repository.IntakeKey{ShelterID: p.Shelter.ID}
+		h := &rowHash{}
+		if err := rows.Scan(&key.AnimalID, &key.KennelNumber, &key.ImpoundNumber, &h.hash, &h.intakeDate); err != nil {
+			return err
+		}
+		p.hashes[key] = h
+	}
+	return rows.Err()
+}
+
+func (p *Postgres) Write(ctx context.Context, row Row) error {
+	intakeDate := row.Intake.IntakeDate
+	if intakeDate != nil && p.watermark != nil && intakeDate.Before(*p.watermark) {
+		p.changes.BeforeWatermark++
+		return nil
+	}
+	if intakeDate != nil && (p.latest == nil || intakeDate.After(*p.latest)) {
+		p.latest = intakeDate
+	}
+
+	hash, err := hashRow(row)
+	if err != nil {
+		return err
+	}
+	key := row.Intake.Key()
+	prev, ok := p.hashes[key]
+	changed := !ok || string(prev.hash) != string(hash)
+	p.resolveAnimal(row, changed)
+	if !changed {
+		prev.seen = true
+		p.changes.Unchanged++
+		return nil
+	}
+
+	// a key repeated within the batch keeps its last row, as separate
+	// writes would
+	if i, dup := p.pendingKey[key]; dup {
+		p.pending[i] = pendingRow{intake: row.Intake, hash: hash}
+	} else {
+		p.pendingKey[key] = len(p.pending)
+		p.pending = append(p.pending, pendingRow{intake: row.Intake, hash: hash})
+	}
+	if ok {
+		p.changes.Changed++
+	} else {
+		p.changes.New++
+	}
+	p.hashes[key] = &rowHash{hash: hash, intakeDate: intakeDate, seen: true}
+
+	if len(p.pending) >= writeBatch {
+		return p.flush(ctx)
+	}
+	return nil
+}
+
+// resolveAnimal keeps the animal as its newest intake has it, so its
+// attributes don't depend on the order of the source's rows. Intakes
+// without a date count as the oldest; of two on the same date, the later
+// row wins.
+func (p *Postgres) resolveAnimal(row Row, changed bool) {
+	key := row.Animal.Key()
+	date := row.Intake.IntakeDate
+	a, ok := p.animals[key]
+	if !ok {
+		p.animals[key] = &animalRow{animal: row.Animal, intakeDate: date, changed: changed}
+		return
+	}
+	if date != nil && (a.intakeDate == nil || !date.Before(*a.intakeDate)) || date == nil && a.intakeDate == nil {
+		a.animal, a.intakeDate = row.Animal, date
+	}
+	a.changed = a.changed || changed
+}
+
+// flush writes the pending intakes and their hashes.
+func (p *Postgres) flush(ctx context.Context) error {
+	if len(p.pending) == 0 {
+		return nil
+	}
+	intakes := make([]repository.Intake, len(p.pending))
+	hashes := make([]hashArg, len(p.pending))
+	for i, r := range p.pending {
+		key := r.intake.Key()
+		intakes[i] = r.intake
+		hashes[i] = hashArg{ShelterID: key.ShelterID, AnimalID: key.AnimalID, KennelNumber: key.KennelNumber,
+			ImpoundNumber: key.ImpoundNumber, Hash: r.hash, IntakeDate: r.intake.IntakeDate, ETLRunID: p.runID}
+	}
+	if err := p.exec(ctx, upsertIntake, intakes); err != nil {
+		return err
+	}
+	if err := p.exec(ctx, upsertHash, hashes); err != nil {
+		return err
+	}
+	p.pending = p.pending[:0]
+	clear(p.pendingKey)
+	return nil
+}
+
+// writeAnimals upserts the animals with a new or changed row, each once.
+func (p *Postgres) writeAnimals(ctx context.Context) error {
+	batch := make([]repository.Animal, 0, writeBatch)
+	for _, a := range p.animals {
+		if !a.changed {
+			continue
+		}
+		batch = append(batch, a.animal)
+		if len(batch) == writeBatch {
+			if err := p.exec(ctx, upsertAnimal, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return p.exec(ctx, upsertAnimal, batch)
+}
+
+// Finish writes what is left of the load and commits it.
+func (p *Postgres) Finish(ctx context.Context, stats Stats) error {
+	if err := p.flush(ctx); err != nil {
+		return err
+	}
+	if err := p.writeAnimals(ctx); err != nil {
+		return err
+	}
+	if err := p.disappear(ctx); err != nil {
+		return err
+	}
+	_, err := p.tx.ExecContext(ctx, `UPDATE etl_runs SET finished_at = NOW(), rows_read = $2,
+		rows_new = $3, rows_changed = $4, rows_unchanged = $5, rows_disappeared = $6, rows_before_watermark = $7,
+		watermark = $8
+		WHERE id = $1`, p.runID, stats.Read,
+		p.changes.New, p.changes.Changed, p.changes.Unchanged, p.changes.Disappeared, p.changes.BeforeWatermark,
+		p.latest)
+	if err != nil {
+		return err
+	}
+	err = p.tx.Commit()
+	p.tx = nil
+	return err
+}
+
+// disappear forgets the rows the previous load had and this one didn't,
+// soft deleting them if asked to. Rows before the watermark weren't
+// compared, so they are left alone.
+func (p *Postgres) disappear(ctx context.Context) error {
+	var animalIDs, kennelNumbers, impoundNumbers []string
+	for key, h := range p.hashes {
+		if h.seen || (h.intakeDate != nil && p.watermark != nil && h.intakeDate.Before(*p.watermark)) {
+			continue
+		}
+		animalIDs = append(animalIDs, key.AnimalID)
+		kennelNumbers = append(kennelNumbers, key.KennelNumber)
+		impoundNumbers = append(impoundNumbers, key.ImpoundNumber)
+	}
+	p.changes.Disappeared = len(animalIDs)
+	if len(animalIDs) == 0 {
+		return nil
+	}
+
+	keys := `(animal_id, kennel_number, impound_number) IN (SELECT * FROM unnest($2::text[], $3::text[], $4::text[]))`
+	args := []interface{}{p.Shelter.ID, pq.Array(animalIDs), pq.Array(kennelNumbers), pq.Array(impoundNumbers)}
+	if _, err := p.tx.ExecContext(ctx, "DELETE FROM etl_row_hashes WHERE shelter_id = $1 AND "+keys, args...); err != nil {
+		return err
+	}
+	if !p.SoftDelete {
+		return nil
+	}
+	_, err := p.tx.ExecContext(ctx, "UPDATE animal_intake SET deleted_at = NOW() WHERE shelter_id = $1 AND deleted_at IS NULL AND "+keys, args...)
+	if err != nil {
+		return err
+	}
+	_, err = p.tx.ExecContext(ctx, `UPDATE animals SET deleted_at = NOW()
+		WHERE shelter_id = $1 AND deleted_at IS NULL AND id = ANY($2::text[]) AND NOT EXISTS (
+			SELECT 1 FROM animal_intake i
+			WHERE i.shelter_id = animals.shelter_id AND i.animal_id = animals.id AND i.deleted_at IS NULL
+		)`, p.Shelter.ID, pq.Array(animalIDs))
+	return err
+}
+
+// Close rolls back a load that didn't finish. The change source set for
+// the load goes with its transaction.
+func (p *Postgres) Close() error {
+	if p.tx == nil {
+		return nil
+	}
+	err := p.tx.Rollback()
+	p.tx = nil
+	return err
+}
+
+// RunID returns the etl_runs id of the load, once Begin has been called.
+func (p *Postgres) RunID() int64 {
+	return p.runID
+}
+
+// Changes returns how the load compared with the previous one, once Finish
+// has been called.
+func (p *Postgres) Changes() Changes {
+	return p.changes
+}
+
+// exec runs a named query in the load's transaction. A slice arg inserts
+// one row per element.
+func (p *Postgres) exec(ctx context.Context, query string, arg interface{}) error {
+	q, args, err := p.DB.BindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+	_, err = p.tx.ExecContext(ctx, q, args...)
+	return err
+}
@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bbrombacher/animals/repository"
+)
+
+// Profiles are the exports cmd/etl knows how to load, by name. A shelter's
+// file export is named after the shelter and its open-data API, if it has
+// one, gets an -api suffix. Add a shelter by describing its columns here; the
+// Postgres sink creates its shelters row on the first load.
+var Profiles = map[string]Profile{
+	Sonoma.Shelter.ID: Sonoma,
+	"sonoma-api":      SonomaAPI,
+}
+
+// LookupProfile returns the profile with the given name.
+func LookupProfile(name string) (Profile, error) {
+	p, ok := Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(Profiles))
+		for name := range Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("no profile %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+// Sonoma is the Sonoma County Animal Services open-data export.
+var Sonoma = Profile{
+	Shelter: repository.Shelter{ID: "sonoma", Name: "Sonoma County Animal Services"},
+	Columns: SonomaColumns,
+	// months and days are usually zero padded, but not always
+	DateLayout: "1/2/2006",
+}
+
+// SonomaAPI is the same data read from the county's open-data API, which
+// writes dates as floating timestamps.
+var SonomaAPI = Profile{
+	Shelter:    Sonoma.Shelter,
+	Columns:    SonomaColumns,
+	DateLayout: "2006-01-02T15:04:05.000",
+	Fields: []string{
+		// the API has no row index; its row id stands in
+		":id",
+		"name", "type", "breed", "color", "sex", "size", "date_of_birth",
+		"impound_number", "kennel_number", "id", "intake_date", "outcome_date", "days_in_shelter",
+		"intake_type", "intake_subtype", "outcome_type", "outcome_subtype",
+		"intake_condition", "outcome_condition", "intake_jurisdiction", "outcome_jurisdiction",
+		"outcome_zip_code", "location", "count",
+	},
+}
+
+// SonomaColumns is the layout of the Sonoma County export, documented in
+// rawdata/header_breakdown.txt. Column 0 is an unnamed row index.
+var SonomaColumns = Columns{
+	// animal
+	AnimalID:    10,
+	AnimalName:  1,
+	AnimalType:  2,
+	Breed:       3,
+	Color:       4,
+	Sex:         5,
+	AnimalSize:  6,
+	DateOfBirth: 7,
+
+	// shelter
+	ImpoundNumber: 8,
+	KennelNumber:  9,
+	// animal_id 10
+	IntakeDate:          11,
+	OutcomeDate:         12,
+	DaysInShelter:       13,
+	IntakeType:          14,
+	IntakeSubtype:       15,
+	OutcomeType:         16,
+	OutcomeSubtype:      17,
+	IntakeCondition:     18,
+	OutcomeCondition:    19,
+	IntakeJurisdiction:  20,
+	OutcomeJurisdiction: 21,
+	ZipCode:             22,
+	Location:            23,
+	AnimalCount:         24,
+}
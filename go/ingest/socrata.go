@@ -0,0 +1,253 @@
+package ingest
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotModified is returned by Socrata.Download when the dataset hasn't
+// changed since the archive's latest snapshot.
+var ErrNotModified = errors.New("dataset not modified since the last download")
+
+// Socrata downloads a dataset from a Socrata-style open-data API, as CSV, a
+// page at a time. Each download is kept in Archive. Once one is loaded,
+// SetLatest records it, and the next download asks the server whether the
+// dataset changed since, with the ETag and Last-Modified it sent.
+type Socrata struct {
+	// URL is the dataset's CSV endpoint, e.g.
+	// https://data.sonomacounty.ca.gov/resource/924a-vesw.csv.
+	URL string
+	// Fields are selected in order, so the download's columns are where
+	// the profile expects them.
+	Fields   []string
+	PageSize int
+	// Retries is how many times a request is retried after a network
+	// error, a 429 or a 5xx, waiting Backoff and then twice as long each
+	// time, or what Retry-After asks for.
+	Retries int
+	Backoff time.Duration
+	// Archive is the directory snapshots are kept in, created if missing.
+	Archive string
+	// Client defaults to defaultClient. A stalled server holds a download,
+	// and a scheduled load's lock, until the client's timeout.
+	Client *http.Client
+}
+
+// defaultClient is the Client of a Socrata without one.
+var defaultClient = &http.Client{Timeout: time.Minute}
+
+// Snapshot is one download of a dataset, kept in the archive.
+type Snapshot struct {
+	// Path is the CSV file of the download.
+	Path         string
+	URL          string
+	ETag         string
+	LastModified string
+	Rows         int
+	DownloadedAt time.Time
+}
+
+// latestSnapshot names the file in the archive describing its latest
+// snapshot.
+const latestSnapshot = "latest.json"
+
+// Download fetches every page of the dataset into a new snapshot in the
+// archive. When the server says the dataset hasn't changed since the latest
+// snapshot it returns that snapshot and ErrNotModified instead.
+func (s Socrata) Download(ctx context.Context) (Snapshot, error) {
+	if err := os.MkdirAll(s.Archive, 0o755); err != nil {
+		return Snapshot{}, err
+	}
+	prev, err := s.Latest()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	f, err := os.CreateTemp(s.Archive, "download-*.csv")
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	snap := Snapshot{URL: s.URL, DownloadedAt: time.Now().UTC()}
+	w := csv.NewWriter(f)
+	var header []string
+	for offset := 0; ; offset += s.PageSize {
+		req, err := s.pageRequest(ctx, offset)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		// the first page stands for the dataset: if it hasn't changed,
+		// nothing has
+		if offset == 0 && prev != nil && prev.URL == s.URL {
+			if prev.ETag != "" {
+				req.Header.Set("If-None-Match", prev.ETag)
+			}
+			if prev.LastModified != "" {
+				req.Header.Set("If-Modified-Since", prev.LastModified)
+			}
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			return *prev, ErrNotModified
+		}
+		if offset == 0 {
+			snap.ETag = resp.Header.Get("ETag")
+			snap.LastModified = resp.Header.Get("Last-Modified")
+		}
+
+		pageHeader, records, err := readPage(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("reading page at offset %d: %w", offset, err)
+		}
+		if header == nil {
+			header = pageHeader
+			if err := w.Write(header); err != nil {
+				return Snapshot{}, err
+			}
+		} else if !slices.Equal(header, pageHeader) {
+			return Snapshot{}, fmt.Errorf("page at offset %d has header %v, want %v", offset, pageHeader, header)
+		}
+		if err := w.WriteAll(records); err != nil {
+			return Snapshot{}, err
+		}
+		snap.Rows += len(records)
+		if len(records) < s.PageSize {
+			break
+		}
+	}
+	if w.Flush(); w.Error() != nil {
+		return Snapshot{}, w.Error()
+	}
+	if err := f.Close(); err != nil {
+		return Snapshot{}, err
+	}
+
+	snap.Path = filepath.Join(s.Archive, snap.DownloadedAt.Format("20060102T150405Z")+".csv")
+	if err := os.Rename(f.Name(), snap.Path); err != nil {
+		return Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// Latest returns the snapshot SetLatest last recorded, or nil if there is
+// none.
+func (s Socrata) Latest() (*Snapshot, error) {
+	b, err := os.ReadFile(filepath.Join(s.Archive, latestSnapshot))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{}
+	if err := json.Unmarshal(b, snap); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", latestSnapshot, err)
+	}
+	return snap, nil
+}
+
+// SetLatest records snap as the snapshot later downloads are compared with.
+// Call it once snap is loaded, so a failed load is downloaded again.
+func (s Socrata) SetLatest(snap Snapshot) error {
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(s.Archive, latestSnapshot+".tmp")
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.Archive, latestSnapshot))
+}
+
+// pageRequest builds the request for the page starting at offset. Rows are
+// ordered by their row id so pages don't overlap.
+func (s Socrata) pageRequest(ctx context.Context, offset int) (*http.Request, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	if len(s.Fields) > 0 {
+		q.Set("$select", strings.Join(s.Fields, ","))
+	}
+	q.Set("$order", ":id")
+	q.Set("$limit", strconv.Itoa(s.PageSize))
+	q.Set("$offset", strconv.Itoa(offset))
+	u.RawQuery = q.Encode()
+	return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+}
+
+// do sends req, retrying failures that may pass. The response is either
+// 200 or 304.
+func (s Socrata) do(req *http.Request) (*http.Response, error) {
+	client := s.Client
+	if client == nil {
+		client = defaultClient
+	}
+	wait := s.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		retry := err != nil
+		if err == nil {
+			switch {
+			case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotModified:
+				return resp, nil
+			case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+				retry = true
+				err = fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+				if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+					wait = time.Duration(secs) * time.Second
+				}
+			default:
+				err = fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if !retry || attempt >= s.Retries {
+			return nil, err
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// readPage reads a page's header and records.
+func readPage(r io.Reader) ([]string, [][]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	records, err := cr.ReadAll()
+	return header, records, err
+}
@@ -0,0 +1,254 @@
+package ingest
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bbrombacher/animals/config"
+)
+
+// standIn serves a dataset the way a Socrata endpoint does: a CSV page per
+// request, with an ETag for conditional GETs.
+type standIn struct {
+	mu       sync.Mutex
+	header   []string
+	rows     [][]string
+	etag     string
+	failures int // requests to answer with 503 before serving
+	requests []*http.Request
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+
+	if s.failures > 0 {
+		s.failures--
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("$limit"))
+	offset, _ := strconv.Atoi(q.Get("$offset"))
+	end := min(offset+limit, len(s.rows))
+	offset = min(offset, end)
+
+	w.Header().Set("ETag", s.etag)
+	cw := csv.NewWriter(w)
+	cw.Write(s.header)
+	cw.WriteAll(s.rows[offset:end])
+}
+
+func newStandIn(rows int) *standIn {
+	s := &standIn{header: SonomaAPI.Fields, etag: `"v1"`}
+	for i := range rows {
+		row := make([]string, len(s.header))
+		row[0] = "row-" + strconv.Itoa(i)
+		row[SonomaColumns.AnimalID] = "A" + strconv.Itoa(i)
+		row[SonomaColumns.IntakeDate] = "2022-08-08T00:00:00.000"
+		row[SonomaColumns.DaysInShelter] = "1"
+		row[SonomaColumns.AnimalCount] = "1"
+		s.rows = append(s.rows, row)
+	}
+	return s
+}
+
+func TestSocrataDownload(t *testing.T) {
+	stand := newStandIn(5)
+	srv := httptest.NewServer(stand)
+	defer srv.Close()
+
+	dl := Socrata{
+		URL:      srv.URL + "/resource/test.csv",
+		Fields:   SonomaAPI.Fields,
+		PageSize: 2,
+		Retries:  1,
+		Backoff:  time.Millisecond,
+		Archive:  t.TempDir(),
+	}
+	stand.failures = 1
+	snap, err := dl.Download(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one retry, then pages of 2, 2 and 1
+	if len(stand.requests) != 4 {
+		t.Errorf("got %d requests, want 4", len(stand.requests))
+	}
+	q := stand.requests[len(stand.requests)-1].URL.Query()
+	if q.Get("$offset") != "4" || q.Get("$limit") != "2" || q.Get("$order") != ":id" ||
+		q.Get("$select") != strings.Join(SonomaAPI.Fields, ",") {
+		t.Errorf("got last query %v", q)
+	}
+	if snap.Rows != 5 || snap.ETag != `"v1"` || snap.URL != dl.URL {
+		t.Errorf("got snapshot %+v", snap)
+	}
+
+	// the snapshot loads with the API profile
+	f, err := os.Open(snap.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	src, err := NewCSVSource(f, snap.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &memorySink{}
+	stats, err := Run(context.Background(), src, SonomaAPI, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Loaded != 5 {
+		t.Errorf("got stats %+v", stats)
+	}
+	if got := sink.rows[4]; got.Animal.ID != "A4" || got.Intake.IntakeDate == nil || got.Intake.IntakeDate.Day() != 8 {
+		t.Errorf("got last row %+v", got)
+	}
+
+	// until a snapshot is recorded as loaded, downloads start over
+	if _, err := dl.Download(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := stand.requests[len(stand.requests)-3].Header.Get("If-None-Match"); got != "" {
+		t.Errorf("got If-None-Match %q before SetLatest", got)
+	}
+
+	if err := dl.SetLatest(snap); err != nil {
+		t.Fatal(err)
+	}
+	got, err := dl.Download(context.Background())
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("got error %v, want ErrNotModified", err)
+	}
+	if got.Path != snap.Path {
+		t.Errorf("got snapshot %+v, want the latest", got)
+	}
+
+	// a changed dataset is downloaded again
+	stand.etag = `"v2"`
+	snap, err = dl.Download(context.Background())
+	if err != nil || snap.ETag != `"v2"` {
+		t.Errorf("got snapshot %+v, %v", snap, err)
+	}
+}
+
+func TestSocrataDownloadGivesUp(t *testing.T) {
+	stand := newStandIn(1)
+	stand.failures = 3
+	srv := httptest.NewServer(stand)
+	defer srv.Close()
+
+	dl := Socrata{URL: srv.URL, PageSize: 10, Retries: 2, Backoff: time.Millisecond, Archive: t.TempDir()}
+	if _, err := dl.Download(context.Background()); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got error %v, want a 503", err)
+	}
+	if len(stand.requests) != 3 {
+		t.Errorf("got %d requests, want 3", len(stand.requests))
+	}
+	entries, _ := os.ReadDir(dl.Archive)
+	if len(entries) != 0 {
+		t.Errorf("got %d files in the archive, want none", len(entries))
+	}
+}
+
+func TestSocrataDownloadStopsOnClientError(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "no such dataset", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	dl := Socrata{URL: srv.URL, PageSize: 10, Retries: 2, Backoff: time.Millisecond, Archive: t.TempDir()}
+	if _, err := dl.Download(context.Background()); err == nil {
+		t.Error("got no error for a 404")
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+}
+
+func TestSocrataDownloadTimesOut(t *testing.T) {
+	stalled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// the headers arrive but the page never does
+		select {
+		case <-stalled:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(stalled)
+
+	job, err := NewJob(config.ETL{Profile: "sonoma-api", SourceURL: srv.URL, PageSize: 10, RequestTimeout: 50 * time.Millisecond,
+		Retries: 1, RetryBackoff: time.Millisecond, Archive: t.TempDir()}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := job.Download.Download(context.Background())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("got no error from a stalled server")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("download didn't time out")
+	}
+}
+
+func TestJobRecordsDownloadOnceLoaded(t *testing.T) {
+	stand := newStandIn(3)
+	srv := httptest.NewServer(stand)
+	defer srv.Close()
+
+	job, err := NewJob(config.ETL{Profile: "sonoma-api", SourceURL: srv.URL, PageSize: 10, Archive: t.TempDir()}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := errors.New("disk full")
+	job.Sink = &failingSink{err: failing}
+	if _, err := job.Run(context.Background()); !errors.Is(err, failing) {
+		t.Fatalf("got error %v, want %v", err, failing)
+	}
+	if latest, err := job.Download.Latest(); latest != nil || err != nil {
+		t.Errorf("got latest %+v, %v after a failed load", latest, err)
+	}
+
+	job.Sink = &memorySink{}
+	res, err := job.Run(context.Background())
+	if err != nil || res.NotModified || res.Stats.Loaded != 3 || res.Snapshot == nil {
+		t.Fatalf("got %+v, %v", res, err)
+	}
+	res, err = job.Run(context.Background())
+	if err != nil || !res.NotModified || res.Stats.Read != 0 {
+		t.Errorf("got %+v, %v for an unchanged dataset", res, err)
+	}
+
+	if _, err := NewJob(config.ETL{Profile: "sonoma", SourceURL: srv.URL}, ""); err == nil {
+		t.Error("got no error downloading with a file profile")
+	}
+}
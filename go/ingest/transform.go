@@ -0,0 +1,158 @@
+package ingest
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbrombacher/animals/repository"
+)
+
+// Columns gives the index of each field in a record. Absent marks a field
+// the export doesn't have, which loads as its zero value.
+type Columns struct {
+	// animal
+	AnimalID    int
+	AnimalName  int
+	AnimalType  int
+	Breed       int
+	Color       int
+	Sex         int
+	AnimalSize  int
+	DateOfBirth int
+
+	// shelter
+	ImpoundNumber       int
+	KennelNumber        int
+	IntakeDate          int
+	OutcomeDate         int
+	DaysInShelter       int
+	IntakeType          int
+	IntakeSubtype       int
+	OutcomeType         int
+	OutcomeSubtype      int
+	IntakeCondition     int
+	OutcomeCondition    int
+	IntakeJurisdiction  int
+	OutcomeJurisdiction int
+	ZipCode             int
+	Location            int
+	AnimalCount         int
+}
+
+// Absent is the index of a column missing from an export.
+const Absent = -1
+
+// width returns the fields a record needs to hold every column.
+func (c Columns) width() int {
+	width := 0
+	v := reflect.ValueOf(c)
+	for i := 0; i < v.NumField(); i++ {
+		width = max(width, int(v.Field(i).Int())+1)
+	}
+	return width
+}
+
+// Profile maps one shelter's export to rows: which shelter it is, where each
+// field sits and how its dates are written.
+type Profile struct {
+	Shelter repository.Shelter
+	Columns Columns
+	// DateLayout is the time.Parse layout of the export's dates, read in
+	// the local time zone.
+	DateLayout string
+	// Fields are the open-data API's field names, in column order, for
+	// profiles read with Socrata. Empty for file exports.
+	Fields []string
+}
+
+var _ Transformer = Profile{}
+
+func (p Profile) Transform(rec Record) (Row, error) {
+	col := p.Columns
+	if width := col.width(); len(rec.Fields) < width {
+		return Row{}, fmt.Errorf("%w: %d fields, want at least %d", ErrMalformed, len(rec.Fields), width)
+	}
+	field := func(i int) string {
+		if i == Absent {
+			return ""
+		}
+		return rec.Fields[i]
+	}
+
+	// days come with thousands separators, e.g. 1,049
+	var daysInShelter int
+	if col.DaysInShelter != Absent {
+		days, err := strconv.Atoi(strings.ReplaceAll(field(col.DaysInShelter), ",", ""))
+		if err != nil {
+			return Row{}, fmt.Errorf("%w: days in shelter: %v", ErrMalformed, err)
+		}
+		daysInShelter = days
+	}
+	// exports without a count list one animal per row
+	animalCount := 1
+	if col.AnimalCount != Absent {
+		count, err := strconv.Atoi(field(col.AnimalCount))
+		if err != nil {
+			return Row{}, fmt.Errorf("%w: animal count: %v", ErrMalformed, err)
+		}
+		animalCount = count
+	}
+	// zip codes come as 95403 or 95403.0, or are empty
+	var zipCode int
+	if zc, _, _ := strings.Cut(field(col.ZipCode), "."); zc != "" {
+		var err error
+		zipCode, err = strconv.Atoi(zc)
+		if err != nil {
+			return Row{}, fmt.Errorf("%w: zip code: %v", ErrMalformed, err)
+		}
+	}
+
+	animal := repository.Animal{
+		ShelterID:   p.Shelter.ID,
+		ID:          field(col.AnimalID),
+		AnimalName:  field(col.AnimalName),
+		AnimalType:  field(col.AnimalType),
+		Breed:       field(col.Breed),
+		Color:       field(col.Color),
+		Sex:         field(col.Sex),
+		AnimalSize:  field(col.AnimalSize),
+		DateOfBirth: p.parseDate(field(col.DateOfBirth)),
+	}
+	if animal.ID == "" {
+		return Row{}, fmt.Errorf("%w: no animal id", ErrMalformed)
+	}
+	intake := repository.Intake{
+		ShelterID:           p.Shelter.ID,
+		ImpoundNumber:       field(col.ImpoundNumber),
+		KennelNumber:        field(col.KennelNumber),
+		AnimalID:            animal.ID,
+		IntakeDate:          p.parseDate(field(col.IntakeDate)),
+		OutcomeDate:         p.parseDate(field(col.OutcomeDate)),
+		DaysInShelter:       daysInShelter,
+		IntakeType:          field(col.IntakeType),
+		IntakeSubtype:       field(col.IntakeSubtype),
+		OutcomeType:         field(col.OutcomeType),
+		OutcomeSubtype:      field(col.OutcomeSubtype),
+		IntakeCondition:     field(col.IntakeCondition),
+		OutcomeCondition:    field(col.OutcomeCondition),
+		IntakeJurisdiction:  field(col.IntakeJurisdiction),
+		OutcomeJurisdiction: field(col.OutcomeJurisdiction),
+		Location:            field(col.Location),
+		AnimalCount:         animalCount,
+		ZipCode:             zipCode,
+	}
+	return Row{Animal: animal, Intake: intake}, nil
+}
+
+// parseDate reads a date in the profile's layout, returning nil for anything
+// else (exports use "unknown" and empty strings).
+func (p Profile) parseDate(date string) *time.Time {
+	t, err := time.ParseInLocation(p.DateLayout, date, time.Local)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/config"
+	"github.com/bbrombacher/animals/ingest"
+	"github.com/bbrombacher/animals/repository"
+	"github.com/bbrombacher/animals/synth"
+	"github.com/bbrombacher/animals/testdb"
+)
+
+// fixtureCSV is a few rows in the Sonoma export's layout: a thousands
+// separator in days_in_shelter, zip codes with and without ".0", unknown
+// dates, an animal with two stays and a duplicated row.
+const fixtureCSV = "testdata/sonoma_fixture.csv"
+
+// TestIntegration loads fixtureCSV through cmd/etl into a fresh database and
+// checks what the API serves from it.
+func TestIntegration(t *testing.T) {
+	db := testdb.New(t)
+	runETL(t, db.URL, fixtureCSV)
+
+	t.Run("etl run", func(t *testing.T) {
+		var run struct {
+			ShelterID string `db:"shelter_id"`
+			RowsRead  int    `db:"rows_read"`
+			Finished  bool   `db:"finished"`
+		}
+		if err := db.Get(&run, "SELECT shelter_id, rows_read, finished_at IS NOT NULL AS finished FROM etl_runs"); err != nil {
+			t.Fatal(err)
+		}
+		if run.ShelterID != "sonoma" || run.RowsRead != 5 || !run.Finished {
+			t.Errorf("got etl run %+v, want sonoma, 5 rows read and finished", run)
+		}
+	})
+
+	t.Run("intakes", func(t *testing.T) {
+		intakes, err := repository.PostgresIntakes{DB: db.DB}.List(context.Background(), repository.IntakeQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []repository.Intake{
+			{ShelterID: "sonoma", ImpoundNumber: "K24-000101", KennelNumber: "DS01", AnimalID: "A100001", IntakeDate: date(2024, 1, 13),
+				DaysInShelter: 12, IntakeType: "STRAY", IntakeSubtype: "FIELD", IntakeCondition: "HEALTHY",
+				IntakeJurisdiction: "SANTA ROSA", AnimalCount: 1},
+			{ShelterID: "sonoma", ImpoundNumber: "K23-000202", KennelNumber: "CS02", AnimalID: "A100002", IntakeDate: date(2023, 12, 2), OutcomeDate: date(2023, 12, 30),
+				DaysInShelter: 28, IntakeType: "OWNER SURRENDER", IntakeSubtype: "OVER THE COUNTER", OutcomeType: "ADOPTION", OutcomeSubtype: "INTERNET",
+				IntakeCondition: "HEALTHY", OutcomeCondition: "HEALTHY", IntakeJurisdiction: "COUNTY", OutcomeJurisdiction: "COUNTY",
+				Location: "95404(38.45, -122.70)", AnimalCount: 1, ZipCode: 95404},
+			{ShelterID: "sonoma", ImpoundNumber: "K24-000303", KennelNumber: "CS03", AnimalID: "A100002", IntakeDate: date(2024, 3, 4),
+				DaysInShelter: 5, IntakeType: "OWNER SURRENDER", IntakeSubtype: "RETURN", IntakeCondition: "TREATABLE/MANAGEABLE",
+				IntakeJurisdiction: "COUNTY", AnimalCount: 1},
+			{ShelterID: "sonoma", ImpoundNumber: "K21-000404", KennelNumber: "RS01", AnimalID: "A100003", IntakeDate: date(2021, 2, 20), OutcomeDate: date(2024, 1, 5),
+				DaysInShelter: 1049, IntakeType: "CONFISCATE", IntakeSubtype: "FIELD", OutcomeType: "TRANSFER", OutcomeSubtype: "PARTNER",
+				IntakeCondition: "UNTREATABLE", OutcomeCondition: "HEALTHY", IntakeJurisdiction: "PETALUMA", OutcomeJurisdiction: "OUT OF COUNTY",
+				Location: "95472(38.40, -122.82)", AnimalCount: 2, ZipCode: 95472},
+		}
+		if len(intakes) != len(want) {
+			t.Fatalf("got %d intakes, want %d", len(intakes), len(want))
+		}
+		for i := range want {
+			got := intakes[i]
+			got.IntakeDate, got.OutcomeDate = inLocal(got.IntakeDate), inLocal(got.OutcomeDate)
+			if !reflect.DeepEqual(got, want[i]) {
+				t.Errorf("intake %s:\n got %+v\nwant %+v", want[i].ImpoundNumber, got, want[i])
+			}
+		}
+	})
+
+	staffKey, err := auth.NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec("INSERT INTO api_keys (name, key_hash, role) VALUES ('integration', $1, 'staff')", auth.HashAPIKey(staffKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t, "-db-url", db.URL)
+	r := newRouter(cfg, db.DB, nil, nil, NewHealth(db.DB, cfg), []auth.Authenticator{auth.APIKeys{DB: db.DB}}, nil)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	t.Run("public sees adoptable animals", func(t *testing.T) {
+		var body struct{ Animals []repository.Animal }
+		getJSON(t, srv.URL+"/v1/go-animals", "", http.StatusOK, &body)
+		if got := animalIDs(body.Animals); !reflect.DeepEqual(got, []string{"A100001", "A100002"}) {
+			t.Errorf("got animals %v", got)
+		}
+		getJSON(t, srv.URL+"/v1/go-animals/A100003", "", http.StatusNotFound, nil)
+	})
+
+	t.Run("staff sees every animal", func(t *testing.T) {
+		var body struct{ Animals []repository.Animal }
+		getJSON(t, srv.URL+"/v1/go-animals", staffKey, http.StatusOK, &body)
+		if got := animalIDs(body.Animals); !reflect.DeepEqual(got, []string{"A100001", "A100002", "A100003"}) {
+			t.Fatalf("got animals %v", got)
+		}
+		for i := range body.Animals {
+			body.Animals[i].DateOfBirth = inLocal(body.Animals[i].DateOfBirth)
+		}
+		want := []repository.Animal{
+			{ShelterID: "sonoma", ID: "A100001", AnimalName: "BISCUIT", AnimalType: "DOG", Breed: "BEAGLE", Color: "TAN/WHITE", Sex: "Male", AnimalSize: "MED", DateOfBirth: date(2019, 4, 1)},
+			{ShelterID: "sonoma", ID: "A100002", AnimalName: "MOCHI", AnimalType: "CAT", Breed: "DOMESTIC SH", Color: "BLACK", Sex: "Female", AnimalSize: "SMALL", DateOfBirth: date(2022, 11, 20)},
+			{ShelterID: "sonoma", ID: "A100003", AnimalName: "Unknown", AnimalType: "RABBIT", Breed: "LOP-HOLLAND", Color: "WHITE", Sex: "Spayed", AnimalSize: "SMALL"},
+		}
+		if !reflect.DeepEqual(body.Animals, want) {
+			t.Errorf("got animals\n%+v\nwant\n%+v", body.Animals, want)
+		}
+	})
+
+	t.Run("shelters", func(t *testing.T) {
+		// another shelter reusing one of the fixture's ids
+		statements := []string{
+			"INSERT INTO shelters (id, name) VALUES ('marin', 'Marin Humane')",
+			"INSERT INTO animals (shelter_id, id, animal_name, animal_type) VALUES ('marin', 'A100001', 'PEPPER', 'CAT')",
+			`INSERT INTO animal_intake (shelter_id, impound_number, kennel_number, animal_id, intake_type, days_in_shelter, animal_count)
+			VALUES ('marin', 'M-1', 'C1', 'A100001', 'STRAY', 3, 1)`,
+		}
+		for _, stmt := range statements {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var body struct{ Animals []repository.Animal }
+		getJSON(t, srv.URL+"/v1/go-animals?shelter=marin", "", http.StatusOK, &body)
+		if len(body.Animals) != 1 || body.Animals[0].ShelterID != "marin" || body.Animals[0].AnimalName != "PEPPER" {
+			t.Errorf("got marin animals %+v", body.Animals)
+		}
+		var one struct{ Animal repository.Animal }
+		getJSON(t, srv.URL+"/v1/go-animals/A100001?shelter=sonoma", "", http.StatusOK, &one)
+		if one.Animal.AnimalName != "BISCUIT" {
+			t.Errorf("got sonoma animal %+v", one.Animal)
+		}
+		getJSON(t, srv.URL+"/v1/go-animals/A100001", "", http.StatusConflict, nil)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		// the shelter later records the rabbit's transfer as an adoption
+		fixture, err := os.ReadFile(fixtureCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		corrected := filepath.Join(t.TempDir(), "corrected.csv")
+		err = os.WriteFile(corrected, []byte(strings.Replace(string(fixture), ",TRANSFER,PARTNER,", ",ADOPTION,PARTNER,", 1)), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runETL(t, db.URL, corrected)
+
+		var updates int
+		if err := db.Get(&updates, "SELECT (SELECT COUNT(*) FROM animal_history WHERE operation = 'UPDATE') + (SELECT COUNT(*) FROM intake_history WHERE operation = 'UPDATE')"); err != nil {
+			t.Fatal(err)
+		}
+		if updates != 1 {
+			t.Errorf("got %d updates from reloading one changed row, want 1", updates)
+		}
+
+		getJSON(t, srv.URL+"/v1/go-animals/A100003/history", "", http.StatusUnauthorized, nil)
+		var body struct{ Changes []repository.Change }
+		getJSON(t, srv.URL+"/v1/go-animals/A100003/history?shelter=sonoma", staffKey, http.StatusOK, &body)
+		if len(body.Changes) != 3 {
+			t.Fatalf("got changes %+v, want the animal and intake inserts and the outcome update", body.Changes)
+		}
+		if c := body.Changes[0]; c.Entity != "animal" || c.Operation != "INSERT" || c.Source != "etl_run:1" {
+			t.Errorf("got first change %+v", c)
+		}
+		update := body.Changes[2]
+		if update.Entity != "intake" || update.Operation != "UPDATE" || update.Field == nil || *update.Field != "outcome_type" ||
+			string(update.OldValue) != `"TRANSFER"` || string(update.NewValue) != `"ADOPTION"` || update.Source != "etl_run:2" {
+			t.Errorf("got update %+v", update)
+		}
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		getJSON(t, srv.URL+"/readyz", "", http.StatusOK, nil)
+	})
+}
+
+// TestIntegrationSynthetic loads generated data, malformed rows included,
+// and checks the ETL keeps exactly the well-formed ones.
+func TestIntegrationSynthetic(t *testing.T) {
+	db := testdb.New(t)
+	csvPath := filepath.Join(t.TempDir(), "synthetic.csv")
+	f, err := os.Create(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := synth.Generate(f, synth.Options{Rows: 2000, Seed: 1, RepeatRate: 0.25, MissingRate: 0.05, MalformedRate: 0.02})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	runETL(t, db.URL, csvPath)
+
+	var got struct {
+		Animals  int `db:"animals"`
+		Intakes  int `db:"intakes"`
+		RowsRead int `db:"rows_read"`
+	}
+	err = db.Get(&got, `SELECT (SELECT COUNT(*) FROM animals) AS animals, (SELECT COUNT(*) FROM animal_intake) AS intakes,
+		(SELECT rows_read FROM etl_runs) AS rows_read`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Animals != stats.Animals || got.Intakes != stats.Intakes || got.RowsRead != stats.Rows {
+		t.Errorf("loaded %+v, want %+v", got, stats)
+	}
+}
+
+// TestIntegrationIncremental reloads edited copies of fixtureCSV and checks
+// only the differences are applied.
+func TestIntegrationIncremental(t *testing.T) {
+	db := testdb.New(t)
+	fixture, err := os.ReadFile(fixtureCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(fixture), "\n")
+	writeCSV := func(lines ...string) string {
+		path := filepath.Join(t.TempDir(), "load.csv")
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	type counts struct {
+		New             int `db:"rows_new"`
+		Changed         int `db:"rows_changed"`
+		Unchanged       int `db:"rows_unchanged"`
+		Disappeared     int `db:"rows_disappeared"`
+		BeforeWatermark int `db:"rows_before_watermark"`
+	}
+	lastRun := func(t *testing.T) counts {
+		t.Helper()
+		var c counts
+		err := db.Get(&c, `SELECT rows_new, rows_changed, rows_unchanged, rows_disappeared, rows_before_watermark
+			FROM etl_runs ORDER BY id DESC LIMIT 1`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	runETL(t, db.URL, fixtureCSV)
+	// the fifth row repeats the first
+	if got, want := lastRun(t), (counts{New: 4, Unchanged: 1}); got != want {
+		t.Errorf("first load: got %+v, want %+v", got, want)
+	}
+
+	runETL(t, db.URL, fixtureCSV)
+	if got, want := lastRun(t), (counts{Unchanged: 5}); got != want {
+		t.Errorf("same file: got %+v, want %+v", got, want)
+	}
+
+	// the rabbit's row disappears and Mochi's second stay ends
+	changed := strings.Replace(lines[3], ",,5,OWNER SURRENDER,RETURN,,,", ",03/09/2024,5,OWNER SURRENDER,RETURN,ADOPTION,,", 1)
+	runETL(t, db.URL, writeCSV(lines[0], lines[1], lines[2], changed), "-etl-soft-delete")
+	if got, want := lastRun(t), (counts{Changed: 1, Unchanged: 2, Disappeared: 1}); got != want {
+		t.Errorf("edited file: got %+v, want %+v", got, want)
+	}
+	var deleted []string
+	if err := db.Select(&deleted, "SELECT id FROM animals WHERE deleted_at IS NOT NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"A100003"}) {
+		t.Errorf("got deleted animals %v, want A100003", deleted)
+	}
+	animals, err := repository.PostgresAnimals{DB: db.DB}.List(context.Background(), repository.AnimalQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := animalIDs(animals); !reflect.DeepEqual(got, []string{"A100001", "A100002"}) {
+		t.Errorf("got animals %v after the soft delete", got)
+	}
+
+	// only Mochi's second stay is on or after the watermark, 03/04/2024
+	runETL(t, db.URL, writeCSV(lines[0], lines[1], lines[2], changed), "-etl-watermark")
+	if got, want := lastRun(t), (counts{Unchanged: 1, BeforeWatermark: 2}); got != want {
+		t.Errorf("watermarked load: got %+v, want %+v", got, want)
+	}
+}
+
+// TestIntegrationLoadResolvesAnimals checks that an animal in several rows
+// takes its attributes from its newest intake, whatever the rows' order.
+func TestIntegrationLoadResolvesAnimals(t *testing.T) {
+	db := testdb.New(t)
+	fixture, err := os.ReadFile(fixtureCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(fixture), "\n")
+	// Mochi was spayed between stays; the older stay comes last
+	newer := strings.Replace(lines[3], ",Female,", ",Spayed,", 1)
+	path := filepath.Join(t.TempDir(), "load.csv")
+	if err := os.WriteFile(path, []byte(lines[0]+newer+lines[2]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runETL(t, db.URL, path)
+
+	var sex string
+	if err := db.Get(&sex, "SELECT sex FROM animals WHERE id = 'A100002'"); err != nil {
+		t.Fatal(err)
+	}
+	if sex != "Spayed" {
+		t.Errorf("got sex %q, want the newest intake's Spayed", sex)
+	}
+}
+
+// TestIntegrationFailedLoad checks that a load that doesn't finish leaves
+// nothing behind.
+func TestIntegrationFailedLoad(t *testing.T) {
+	db := testdb.New(t)
+	ctx := context.Background()
+	f, err := os.Open(fixtureCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	src, err := ingest.NewCSVSource(f, fixtureCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg := &ingest.Postgres{DB: db.DB, Shelter: ingest.Sonoma.Shelter}
+	if _, err := ingest.Run(ctx, src, ingest.Sonoma, unfinishedSink{pg}); err == nil {
+		t.Fatal("the load didn't fail")
+	}
+	if err := pg.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, table := range []string{"etl_runs", "animals", "animal_intake", "etl_row_hashes", "animal_history", "intake_history"} {
+		var n int
+		if err := db.Get(&n, "SELECT count(*) FROM "+table); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("got %d rows in %s after a failed load", n, table)
+		}
+	}
+}
+
+// unfinishedSink fails a load after its last row.
+type unfinishedSink struct {
+	*ingest.Postgres
+}
+
+func (s unfinishedSink) Finish(ctx context.Context, stats ingest.Stats) error {
+	return errors.New("load failed")
+}
+
+// TestIntegrationDayFirstReload checks that after the day first dates
+// migration the next load corrects a date an old load read day first.
+func TestIntegrationDayFirstReload(t *testing.T) {
+	db := testdb.New(t)
+	runETL(t, db.URL, fixtureCSV)
+
+	// 01/13/2024 read day first was month 13 of 2024
+	intakeDate := func() *time.Time {
+		t.Helper()
+		var d time.Time
+		if err := db.Get(&d, "SELECT intake_date FROM animal_intake WHERE animal_id = 'A100001'"); err != nil {
+			t.Fatal(err)
+		}
+		return inLocal(&d)
+	}
+	if _, err := db.Exec("UPDATE animal_intake SET intake_date = $1 WHERE animal_id = 'A100001'", date(2025, 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	runETL(t, db.URL, fixtureCSV)
+	if got := intakeDate(); !got.Equal(*date(2025, 1, 1)) {
+		t.Fatalf("got intake date %v; an unchanged row should have been skipped", got)
+	}
+
+	migration, err := os.ReadFile(filepath.Join(testdb.RepoRoot(), "migrations", "2022010020001931_reload_day_first_dates.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(migration)); err != nil {
+		t.Fatal(err)
+	}
+	runETL(t, db.URL, fixtureCSV)
+	if got := intakeDate(); !got.Equal(*date(2024, 1, 13)) {
+		t.Errorf("got intake date %v after the reload, want 2024-01-13", got)
+	}
+}
+
+// TestIntegrationScheduledLoad runs the server's scheduled load while
+// another instance holds the lock, and then once it is free.
+func TestIntegrationScheduledLoad(t *testing.T) {
+	db := testdb.New(t)
+	ctx := context.Background()
+	cfg := config.Config{CSVPath: fixtureCSV, ETL: config.ETL{Profile: "sonoma"}}
+
+	other, err := db.Connx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.ExecContext(ctx, "SELECT pg_advisory_lock($1)", ingest.LockKey); err != nil {
+		t.Fatal(err)
+	}
+	if run := loadLocked(ctx, db.DB, cfg); run.Status != IngestLocked || run.ETLRun != 0 {
+		t.Errorf("got run %+v while locked", run)
+	}
+	// cmd/etl's loads take the same lock
+	pg := &ingest.Postgres{DB: db.DB, Shelter: ingest.Sonoma.Shelter}
+	if err := pg.Begin(ctx, fixtureCSV); !errors.Is(err, ingest.ErrLocked) {
+		t.Errorf("got error %v beginning a load while locked, want ErrLocked", err)
+	}
+	if err := pg.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", ingest.LockKey); err != nil {
+		t.Fatal(err)
+	}
+
+	run := loadLocked(ctx, db.DB, cfg)
+	if run.Status != IngestSucceeded || run.ETLRun == 0 || run.Stats.Read != 5 || run.Changes.New != 4 {
+		t.Errorf("got run %+v", run)
+	}
+	var finished bool
+	if err := db.Get(&finished, "SELECT finished_at IS NOT NULL FROM etl_runs WHERE id = $1", run.ETLRun); err != nil || !finished {
+		t.Errorf("etl run %d finished %v, %v", run.ETLRun, finished, err)
+	}
+}
+
+// runETL builds cmd/etl and loads csvPath into the database at dbURL, with
+// any extra flags.
+func runETL(t *testing.T, dbURL, csvPath string, flags ...string) {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "etl")
+	if out, err := exec.Command("go", "build", "-o", bin, "./cmd/etl").CombinedOutput(); err != nil {
+		t.Fatalf("building etl: %v\n%s", err, out)
+	}
+	args := append([]string{"-db-url", dbURL, "-csv-path", csvPath, "-log-format", "text"}, flags...)
+	out, err := exec.Command(bin, args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("running etl: %v\n%s", err, out)
+	}
+}
+
+func getJSON(t *testing.T, url, apiKey string, status int, dest interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("GET %s: got status %d, want %d", url, resp.StatusCode, status)
+	}
+	if dest != nil {
+		if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+			t.Fatalf("GET %s: decoding response: %v", url, err)
+		}
+	}
+}
+
+func animalIDs(animals []repository.Animal) []string {
+	ids := []string{}
+	for _, a := range animals {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+// date is midnight local time, which is how the ETL stores dates.
+func date(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	return &d
+}
+
+// inLocal rebuilds t in local time so it compares equal to date's results.
+func inLocal(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	l := t.In(time.Local)
+	local := time.Date(l.Year(), l.Month(), l.Day(), l.Hour(), l.Minute(), l.Second(), l.Nanosecond(), time.Local)
+	return &local
+}
@@ -0,0 +1,148 @@
+// Package logging builds the structured loggers used by every binary and
+// carries request scoped attributes, such as the request ID, on contexts.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ctxKey int
+
+const requestIDKey ctxKey = iota
+
+// redactedKeys are attribute keys whose values are never logged.
+var redactedKeys = map[string]bool{
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"authorization": true,
+	"api_key":       true,
+}
+
+// New returns a logger writing to w. level is one of debug, info, warn or
+// error and format is json or text.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, want json or text", format)
+	}
+}
+
+// redact masks secret attributes and the passwords in string, error and
+// fmt.Stringer values, so a DB URL, or an error wrapping one, can be logged
+// without leaking credentials.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "xxxxx")
+	}
+	var value string
+	switch a.Value.Kind() {
+	case slog.KindString:
+		value = a.Value.String()
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			value = v.Error()
+		case fmt.Stringer:
+			value = v.String()
+		default:
+			return a
+		}
+	default:
+		return a
+	}
+	if redacted := redactString(value); redacted != value {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+var (
+	// urlPattern matches URLs within a longer string, such as an error.
+	urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+	// dsnPassword matches the password in a key=value connection string.
+	dsnPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s&"]+)`)
+)
+
+// redactString masks the userinfo password of every URL in s, and any
+// password=value pair, in a query string or a connection string.
+func redactString(s string) string {
+	if !strings.Contains(s, "://") && !strings.Contains(strings.ToLower(s), "password") {
+		return s
+	}
+	s = urlPattern.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil || u.User == nil {
+			return raw
+		}
+		if _, ok := u.User.Password(); !ok {
+			return raw
+		}
+		return u.Redacted()
+	})
+	return dsnPassword.ReplaceAllString(s, "${1}xxxxx")
+}
+
+// NewRequestID returns a random 16 byte hex ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID on ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// FromContext returns the default logger annotated with the request ID and
+// trace ID on ctx, if any.
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
+
+// SetDefault installs a logger built by New as the slog default, which also
+// routes the standard log package through it.
+func SetDefault(w io.Writer, level, format string) error {
+	logger, err := New(w, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "animals_http_requests_total",
+		Help: "HTTP requests served, by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "animals_http_request_duration_seconds",
+		Help:    "HTTP request latency, by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	ingestRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "animals_ingest_runs_total",
+		Help: "Scheduled ETL runs, by status: succeeded, failed, not_modified or locked.",
+	}, []string{"status"})
+
+	ingestLastRun = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "animals_ingest_last_run_timestamp_seconds",
+		Help: "When the last scheduled ETL run this instance made, by status, started.",
+	}, []string{"status"})
+
+	ingestLastDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "animals_ingest_last_run_duration_seconds",
+		Help: "How long the last scheduled ETL run this instance made took.",
+	})
+
+	ingestLastRows = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "animals_ingest_last_run_rows",
+		Help: "Rows of the last scheduled ETL run this instance made, by kind: read, loaded, skipped, new, changed, unchanged, disappeared or before_watermark.",
+	}, []string{"kind"})
+)
+
+// observeIngestRun records a scheduled load. Runs that found the lock taken
+// are only counted, as this instance didn't load.
+func observeIngestRun(run IngestRun) {
+	ingestRuns.WithLabelValues(run.Status).Inc()
+	if run.Status == IngestLocked {
+		return
+	}
+	ingestLastRun.WithLabelValues(run.Status).Set(float64(run.StartedAt.Unix()))
+	ingestLastDuration.Set(run.DurationSeconds)
+	rows := map[string]int{
+		"read":             run.Stats.Read,
+		"loaded":           run.Stats.Loaded,
+		"skipped":          run.Stats.Skipped,
+		"new":              run.Changes.New,
+		"changed":          run.Changes.Changed,
+		"unchanged":        run.Changes.Unchanged,
+		"disappeared":      run.Changes.Disappeared,
+		"before_watermark": run.Changes.BeforeWatermark,
+	}
+	for kind, n := range rows {
+		ingestLastRows.WithLabelValues(kind).Set(float64(n))
+	}
+}
+
+// registerDBMetrics exports the pool's sql.DBStats (open, in use and idle
+// connections, wait count and wait duration) on every scrape.
+func registerDBMetrics(db *sqlx.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB, "shelters"))
+}
+
+// metricsMiddleware records a request count and latency for every routed
+// request.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, req)
+
+		labels := prometheus.Labels{
+			"route":  routeName(req),
+			"method": req.Method,
+			"status": strconv.Itoa(rec.status),
+		}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
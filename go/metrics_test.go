@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrape returns the value of the series named by metric and its labels in
+// /metrics' text format, or 0 if it hasn't been recorded.
+func scrape(t *testing.T, series string) float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	lines := bufio.NewScanner(rec.Body)
+	for lines.Scan() {
+		value, ok := strings.CutPrefix(lines.Text(), series+" ")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	return 0
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(metricsMiddleware)
+	r.HandleFunc("/v1/metrics-test/{id}", func(w http.ResponseWriter, req *http.Request) {
+		switch mux.Vars(req)["id"] {
+		case "missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "broken":
+			http.Error(w, "failed", http.StatusInternalServerError)
+		default:
+			w.Write([]byte("{}"))
+		}
+	})
+
+	series := func(status string) string {
+		return `animals_http_requests_total{method="GET",route="/v1/metrics-test/{id}",status="` + status + `"}`
+	}
+	before := map[string]float64{}
+	for _, status := range []string{"200", "404", "500"} {
+		before[status] = scrape(t, series(status))
+	}
+
+	for _, path := range []string{"/v1/metrics-test/A1", "/v1/metrics-test/A2", "/v1/metrics-test/missing", "/v1/metrics-test/broken"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// both animals count against the route, not their own paths
+	for status, want := range map[string]float64{"200": 2, "404": 1, "500": 1} {
+		if got := scrape(t, series(status)) - before[status]; got != want {
+			t.Errorf("status %s: counted %v requests, want %v", status, got, want)
+		}
+	}
+	if got := scrape(t, `animals_http_requests_total{method="GET",route="/v1/metrics-test/A1",status="200"}`); got != 0 {
+		t.Errorf("counted %v requests under the raw path", got)
+	}
+	if got := scrape(t, `animals_http_request_duration_seconds_count{method="GET",route="/v1/metrics-test/{id}",status="404"}`); got < 1 {
+		t.Errorf("observed %v latencies for the 404, want at least 1", got)
+	}
+}
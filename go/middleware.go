@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bbrombacher/animals/logging"
+	"github.com/gorilla/mux"
+)
+
+// responseRecorder captures the status code and body size written by a
+// handler so middleware can report them after the handler returns.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// routeName returns the mux path template for req so metrics and logs are
+// labelled by route rather than by raw path.
+func routeName(req *http.Request) string {
+	route := mux.CurrentRoute(req)
+	if route == nil {
+		return "unmatched"
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return "unmatched"
+	}
+	return tmpl
+}
+
+const requestIDHeader = "X-Request-ID"
+
+// requestIDMiddleware propagates the caller's X-Request-ID, or assigns a new
+// one, and stores it on the request context for logging.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, req.WithContext(logging.WithRequestID(req.Context(), id)))
+	})
+}
+
+// validRequestID rejects IDs that are empty, too long or could break log
+// lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// accessLogMiddleware logs one line per request once the handler returns.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, req)
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(req.Context()).Log(req.Context(), level, "request",
+			"method", req.Method,
+			"route", routeName(req),
+			"path", req.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"latency", time.Since(start),
+		)
+	})
+}
+
+// writeError logs err against the request and sends a JSON error body.
+func writeError(w http.ResponseWriter, req *http.Request, status int, msg string, err error) {
+	logging.FromContext(req.Context()).Error(msg, "error", err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("%s %v", msg, err.Error()),
+	})
+}
+
+// writeNotFound sends a 404 JSON error body.
+func writeNotFound(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": msg,
+	})
+}
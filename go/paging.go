@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/repository"
+)
+
+// The helpers below are shared by the GraphQL and gRPC APIs so both page
+// and hide rows from public callers the same way.
+
+// adoptableOnly reports whether the caller may only see adoptable animals.
+func adoptableOnly(ctx context.Context) bool {
+	return !auth.FromContext(ctx).CanSeeOperationalData()
+}
+
+// animalPage returns up to limit animals matching q, starting after the
+// cursor after, and whether another page follows.
+func animalPage(ctx context.Context, animals repository.AnimalRepository, q repository.AnimalQuery, after *string, limit int) ([]repository.Animal, bool, error) {
+	if after != nil {
+		keys, err := decodeCursor(*after, 2)
+		if err != nil {
+			return nil, false, err
+		}
+		q.After = &repository.AnimalKey{ShelterID: keys[0], ID: keys[1]}
+	}
+	q.AdoptableOnly = adoptableOnly(ctx)
+	q.Limit = limit + 1
+
+	rows, err := animals.List(ctx, q)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(rows) > limit {
+		return rows[:limit], true, nil
+	}
+	return rows, false, nil
+}
+
+// intakePage returns up to limit intakes matching q, starting after the
+// cursor after, and whether another page follows.
+func intakePage(ctx context.Context, intakes repository.IntakeRepository, q repository.IntakeQuery, after *string, limit int) ([]repository.Intake, bool, error) {
+	if after != nil {
+		keys, err := decodeCursor(*after, 4)
+		if err != nil {
+			return nil, false, err
+		}
+		q.After = &repository.IntakeKey{ShelterID: keys[0], AnimalID: keys[1], KennelNumber: keys[2], ImpoundNumber: keys[3]}
+	}
+	q.AdoptableOnly = adoptableOnly(ctx)
+	q.Limit = limit + 1
+
+	rows, err := intakes.List(ctx, q)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(rows) > limit {
+		return rows[:limit], true, nil
+	}
+	return rows, false, nil
+}
+
+func animalCursor(a repository.Animal) string {
+	key := a.Key()
+	return encodeCursor(key.ShelterID, key.ID)
+}
+
+func intakeCursor(i repository.Intake) string {
+	key := i.Key()
+	return encodeCursor(key.ShelterID, key.AnimalID, key.KennelNumber, key.ImpoundNumber)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/schema"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// queryParams is implemented by every handler's parameter struct so
+// parseQuery can fill in defaults before decoding and check bounds after.
+type queryParams interface {
+	setDefaults()
+	validate() ParamErrors
+}
+
+// ParamErrors maps a query parameter name to the reason it was rejected.
+type ParamErrors map[string]string
+
+func (p ParamErrors) Error() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", k, p[k]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// parseQuery decodes the request's query string into dst. Missing values
+// keep the defaults set by dst, and any decode or bounds failure is
+// returned as ParamErrors keyed by field.
+func parseQuery(req *http.Request, dst queryParams) error {
+	dst.setDefaults()
+
+	if err := decoder.Decode(dst, req.URL.Query()); err != nil {
+		var multi schema.MultiError
+		if !errors.As(err, &multi) {
+			return ParamErrors{"query": err.Error()}
+		}
+		paramErrs := ParamErrors{}
+		for key, fieldErr := range multi {
+			var convErr schema.ConversionError
+			if errors.As(fieldErr, &convErr) {
+				paramErrs[convErr.Key] = fmt.Sprintf("must be a valid %v", convErr.Type)
+				continue
+			}
+			paramErrs[key] = fieldErr.Error()
+		}
+		return paramErrs
+	}
+
+	if paramErrs := dst.validate(); len(paramErrs) > 0 {
+		return paramErrs
+	}
+	return nil
+}
+
+// writeParamErrors sends the standard 400 response for a parseQuery failure.
+func writeParamErrors(w http.ResponseWriter, err error) {
+	resp := map[string]interface{}{
+		"error": fmt.Sprintf("failed to parse query parameters %v", err.Error()),
+	}
+	var paramErrs ParamErrors
+	if errors.As(err, &paramErrs) {
+		resp["fields"] = paramErrs
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// checkRange records an error on errs when value falls outside [min, max].
+func checkRange(errs ParamErrors, name string, value, min, max int) {
+	if value < min || value > max {
+		errs[name] = fmt.Sprintf("must be between %d and %d", min, max)
+	}
+}
+
+// encodeCursor packs the key columns of a row into an opaque cursor.
+func encodeCursor(keys ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(keys, "\x00")))
+}
+
+// errInvalidCursor is returned for cursors this server didn't issue.
+var errInvalidCursor = errors.New("invalid cursor")
+
+func decodeCursor(cursor string, parts int) ([]string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	keys := strings.Split(string(b), "\x00")
+	if len(keys) != parts {
+		return nil, errInvalidCursor
+	}
+	return keys, nil
+}
@@ -0,0 +1,921 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: animals/v1/animals.proto
+
+package animalsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Animal struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	AnimalType    string                 `protobuf:"bytes,3,opt,name=animal_type,json=animalType,proto3" json:"animal_type,omitempty"`
+	Breed         string                 `protobuf:"bytes,4,opt,name=breed,proto3" json:"breed,omitempty"`
+	Color         string                 `protobuf:"bytes,5,opt,name=color,proto3" json:"color,omitempty"`
+	Sex           string                 `protobuf:"bytes,6,opt,name=sex,proto3" json:"sex,omitempty"`
+	AnimalSize    string                 `protobuf:"bytes,7,opt,name=animal_size,json=animalSize,proto3" json:"animal_size,omitempty"`
+	DateOfBirth   *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=date_of_birth,json=dateOfBirth,proto3" json:"date_of_birth,omitempty"`
+	ShelterId     string                 `protobuf:"bytes,9,opt,name=shelter_id,json=shelterId,proto3" json:"shelter_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Animal) Reset() {
+	*x = Animal{}
+	mi := &file_animals_v1_animals_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Animal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Animal) ProtoMessage() {}
+
+func (x *Animal) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Animal.ProtoReflect.Descriptor instead.
+func (*Animal) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Animal) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Animal) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Animal) GetAnimalType() string {
+	if x != nil {
+		return x.AnimalType
+	}
+	return ""
+}
+
+func (x *Animal) GetBreed() string {
+	if x != nil {
+		return x.Breed
+	}
+	return ""
+}
+
+func (x *Animal) GetColor() string {
+	if x != nil {
+		return x.Color
+	}
+	return ""
+}
+
+func (x *Animal) GetSex() string {
+	if x != nil {
+		return x.Sex
+	}
+	return ""
+}
+
+func (x *Animal) GetAnimalSize() string {
+	if x != nil {
+		return x.AnimalSize
+	}
+	return ""
+}
+
+func (x *Animal) GetDateOfBirth() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DateOfBirth
+	}
+	return nil
+}
+
+func (x *Animal) GetShelterId() string {
+	if x != nil {
+		return x.ShelterId
+	}
+	return ""
+}
+
+type Intake struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	ImpoundNumber    string                 `protobuf:"bytes,1,opt,name=impound_number,json=impoundNumber,proto3" json:"impound_number,omitempty"`
+	KennelNumber     string                 `protobuf:"bytes,2,opt,name=kennel_number,json=kennelNumber,proto3" json:"kennel_number,omitempty"`
+	AnimalId         string                 `protobuf:"bytes,3,opt,name=animal_id,json=animalId,proto3" json:"animal_id,omitempty"`
+	IntakeDate       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=intake_date,json=intakeDate,proto3" json:"intake_date,omitempty"`
+	OutcomeDate      *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=outcome_date,json=outcomeDate,proto3" json:"outcome_date,omitempty"`
+	DaysInShelter    int32                  `protobuf:"varint,6,opt,name=days_in_shelter,json=daysInShelter,proto3" json:"days_in_shelter,omitempty"`
+	IntakeType       string                 `protobuf:"bytes,7,opt,name=intake_type,json=intakeType,proto3" json:"intake_type,omitempty"`
+	IntakeSubtype    string                 `protobuf:"bytes,8,opt,name=intake_subtype,json=intakeSubtype,proto3" json:"intake_subtype,omitempty"`
+	OutcomeType      string                 `protobuf:"bytes,9,opt,name=outcome_type,json=outcomeType,proto3" json:"outcome_type,omitempty"`
+	OutcomeSubtype   string                 `protobuf:"bytes,10,opt,name=outcome_subtype,json=outcomeSubtype,proto3" json:"outcome_subtype,omitempty"`
+	IntakeCondition  string                 `protobuf:"bytes,11,opt,name=intake_condition,json=intakeCondition,proto3" json:"intake_condition,omitempty"`
+	OutcomeCondition string                 `protobuf:"bytes,12,opt,name=outcome_condition,json=outcomeCondition,proto3" json:"outcome_condition,omitempty"`
+	// Empty for public callers.
+	IntakeJurisdiction string `protobuf:"bytes,13,opt,name=intake_jurisdiction,json=intakeJurisdiction,proto3" json:"intake_jurisdiction,omitempty"`
+	// Empty for public callers.
+	OutcomeJurisdiction string `protobuf:"bytes,14,opt,name=outcome_jurisdiction,json=outcomeJurisdiction,proto3" json:"outcome_jurisdiction,omitempty"`
+	// Empty for public callers.
+	Location    string `protobuf:"bytes,15,opt,name=location,proto3" json:"location,omitempty"`
+	AnimalCount int32  `protobuf:"varint,16,opt,name=animal_count,json=animalCount,proto3" json:"animal_count,omitempty"`
+	// 0 for public callers.
+	ZipCode       int32  `protobuf:"varint,17,opt,name=zip_code,json=zipCode,proto3" json:"zip_code,omitempty"`
+	ShelterId     string `protobuf:"bytes,18,opt,name=shelter_id,json=shelterId,proto3" json:"shelter_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Intake) Reset() {
+	*x = Intake{}
+	mi := &file_animals_v1_animals_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Intake) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Intake) ProtoMessage() {}
+
+func (x *Intake) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Intake.ProtoReflect.Descriptor instead.
+func (*Intake) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Intake) GetImpoundNumber() string {
+	if x != nil {
+		return x.ImpoundNumber
+	}
+	return ""
+}
+
+func (x *Intake) GetKennelNumber() string {
+	if x != nil {
+		return x.KennelNumber
+	}
+	return ""
+}
+
+func (x *Intake) GetAnimalId() string {
+	if x != nil {
+		return x.AnimalId
+	}
+	return ""
+}
+
+func (x *Intake) GetIntakeDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.IntakeDate
+	}
+	return nil
+}
+
+func (x *Intake) GetOutcomeDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OutcomeDate
+	}
+	return nil
+}
+
+func (x *Intake) GetDaysInShelter() int32 {
+	if x != nil {
+		return x.DaysInShelter
+	}
+	return 0
+}
+
+func (x *Intake) GetIntakeType() string {
+	if x != nil {
+		return x.IntakeType
+	}
+	return ""
+}
+
+func (x *Intake) GetIntakeSubtype() string {
+	if x != nil {
+		return x.IntakeSubtype
+	}
+	return ""
+}
+
+func (x *Intake) GetOutcomeType() string {
+	if x != nil {
+		return x.OutcomeType
+	}
+	return ""
+}
+
+func (x *Intake) GetOutcomeSubtype() string {
+	if x != nil {
+		return x.OutcomeSubtype
+	}
+	return ""
+}
+
+func (x *Intake) GetIntakeCondition() string {
+	if x != nil {
+		return x.IntakeCondition
+	}
+	return ""
+}
+
+func (x *Intake) GetOutcomeCondition() string {
+	if x != nil {
+		return x.OutcomeCondition
+	}
+	return ""
+}
+
+func (x *Intake) GetIntakeJurisdiction() string {
+	if x != nil {
+		return x.IntakeJurisdiction
+	}
+	return ""
+}
+
+func (x *Intake) GetOutcomeJurisdiction() string {
+	if x != nil {
+		return x.OutcomeJurisdiction
+	}
+	return ""
+}
+
+func (x *Intake) GetLocation() string {
+	if x != nil {
+		return x.Location
+	}
+	return ""
+}
+
+func (x *Intake) GetAnimalCount() int32 {
+	if x != nil {
+		return x.AnimalCount
+	}
+	return 0
+}
+
+func (x *Intake) GetZipCode() int32 {
+	if x != nil {
+		return x.ZipCode
+	}
+	return 0
+}
+
+func (x *Intake) GetShelterId() string {
+	if x != nil {
+		return x.ShelterId
+	}
+	return ""
+}
+
+// AnimalFilter matches animals whose fields equal every set value.
+type AnimalFilter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	AnimalType    string                 `protobuf:"bytes,1,opt,name=animal_type,json=animalType,proto3" json:"animal_type,omitempty"`
+	Breed         string                 `protobuf:"bytes,2,opt,name=breed,proto3" json:"breed,omitempty"`
+	Color         string                 `protobuf:"bytes,3,opt,name=color,proto3" json:"color,omitempty"`
+	Sex           string                 `protobuf:"bytes,4,opt,name=sex,proto3" json:"sex,omitempty"`
+	AnimalSize    string                 `protobuf:"bytes,5,opt,name=animal_size,json=animalSize,proto3" json:"animal_size,omitempty"`
+	ShelterId     string                 `protobuf:"bytes,6,opt,name=shelter_id,json=shelterId,proto3" json:"shelter_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnimalFilter) Reset() {
+	*x = AnimalFilter{}
+	mi := &file_animals_v1_animals_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnimalFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnimalFilter) ProtoMessage() {}
+
+func (x *AnimalFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnimalFilter.ProtoReflect.Descriptor instead.
+func (*AnimalFilter) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AnimalFilter) GetAnimalType() string {
+	if x != nil {
+		return x.AnimalType
+	}
+	return ""
+}
+
+func (x *AnimalFilter) GetBreed() string {
+	if x != nil {
+		return x.Breed
+	}
+	return ""
+}
+
+func (x *AnimalFilter) GetColor() string {
+	if x != nil {
+		return x.Color
+	}
+	return ""
+}
+
+func (x *AnimalFilter) GetSex() string {
+	if x != nil {
+		return x.Sex
+	}
+	return ""
+}
+
+func (x *AnimalFilter) GetAnimalSize() string {
+	if x != nil {
+		return x.AnimalSize
+	}
+	return ""
+}
+
+func (x *AnimalFilter) GetShelterId() string {
+	if x != nil {
+		return x.ShelterId
+	}
+	return ""
+}
+
+type ListAnimalsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to 100, at most 1000.
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token from the previous response.
+	PageToken     string        `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	Filter        *AnimalFilter `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAnimalsRequest) Reset() {
+	*x = ListAnimalsRequest{}
+	mi := &file_animals_v1_animals_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAnimalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAnimalsRequest) ProtoMessage() {}
+
+func (x *ListAnimalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAnimalsRequest.ProtoReflect.Descriptor instead.
+func (*ListAnimalsRequest) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListAnimalsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListAnimalsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListAnimalsRequest) GetFilter() *AnimalFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type ListAnimalsResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Animals []*Animal              `protobuf:"bytes,1,rep,name=animals,proto3" json:"animals,omitempty"`
+	// Empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAnimalsResponse) Reset() {
+	*x = ListAnimalsResponse{}
+	mi := &file_animals_v1_animals_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAnimalsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAnimalsResponse) ProtoMessage() {}
+
+func (x *ListAnimalsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAnimalsResponse.ProtoReflect.Descriptor instead.
+func (*ListAnimalsResponse) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListAnimalsResponse) GetAnimals() []*Animal {
+	if x != nil {
+		return x.Animals
+	}
+	return nil
+}
+
+func (x *ListAnimalsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type GetAnimalRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Needed when more than one shelter has an animal with the id.
+	ShelterId     string `protobuf:"bytes,2,opt,name=shelter_id,json=shelterId,proto3" json:"shelter_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAnimalRequest) Reset() {
+	*x = GetAnimalRequest{}
+	mi := &file_animals_v1_animals_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAnimalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAnimalRequest) ProtoMessage() {}
+
+func (x *GetAnimalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAnimalRequest.ProtoReflect.Descriptor instead.
+func (*GetAnimalRequest) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetAnimalRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetAnimalRequest) GetShelterId() string {
+	if x != nil {
+		return x.ShelterId
+	}
+	return ""
+}
+
+type ListIntakesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to 100, at most 1000.
+	PageSize int32 `protobuf:"varint,1,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token from the previous response.
+	PageToken   string `protobuf:"bytes,2,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	AnimalId    string `protobuf:"bytes,3,opt,name=animal_id,json=animalId,proto3" json:"animal_id,omitempty"`
+	IntakeType  string `protobuf:"bytes,4,opt,name=intake_type,json=intakeType,proto3" json:"intake_type,omitempty"`
+	OutcomeType string `protobuf:"bytes,5,opt,name=outcome_type,json=outcomeType,proto3" json:"outcome_type,omitempty"`
+	// When set, only intakes with (true) or without (false) an outcome.
+	InShelter     *bool  `protobuf:"varint,6,opt,name=in_shelter,json=inShelter,proto3,oneof" json:"in_shelter,omitempty"`
+	ShelterId     string `protobuf:"bytes,7,opt,name=shelter_id,json=shelterId,proto3" json:"shelter_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListIntakesRequest) Reset() {
+	*x = ListIntakesRequest{}
+	mi := &file_animals_v1_animals_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListIntakesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListIntakesRequest) ProtoMessage() {}
+
+func (x *ListIntakesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListIntakesRequest.ProtoReflect.Descriptor instead.
+func (*ListIntakesRequest) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListIntakesRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListIntakesRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+func (x *ListIntakesRequest) GetAnimalId() string {
+	if x != nil {
+		return x.AnimalId
+	}
+	return ""
+}
+
+func (x *ListIntakesRequest) GetIntakeType() string {
+	if x != nil {
+		return x.IntakeType
+	}
+	return ""
+}
+
+func (x *ListIntakesRequest) GetOutcomeType() string {
+	if x != nil {
+		return x.OutcomeType
+	}
+	return ""
+}
+
+func (x *ListIntakesRequest) GetInShelter() bool {
+	if x != nil && x.InShelter != nil {
+		return *x.InShelter
+	}
+	return false
+}
+
+func (x *ListIntakesRequest) GetShelterId() string {
+	if x != nil {
+		return x.ShelterId
+	}
+	return ""
+}
+
+type ListIntakesResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Intakes []*Intake              `protobuf:"bytes,1,rep,name=intakes,proto3" json:"intakes,omitempty"`
+	// Empty on the last page.
+	NextPageToken string `protobuf:"bytes,2,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListIntakesResponse) Reset() {
+	*x = ListIntakesResponse{}
+	mi := &file_animals_v1_animals_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListIntakesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListIntakesResponse) ProtoMessage() {}
+
+func (x *ListIntakesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListIntakesResponse.ProtoReflect.Descriptor instead.
+func (*ListIntakesResponse) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListIntakesResponse) GetIntakes() []*Intake {
+	if x != nil {
+		return x.Intakes
+	}
+	return nil
+}
+
+func (x *ListIntakesResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+type ExportAnimalsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filter        *AnimalFilter          `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportAnimalsRequest) Reset() {
+	*x = ExportAnimalsRequest{}
+	mi := &file_animals_v1_animals_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportAnimalsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportAnimalsRequest) ProtoMessage() {}
+
+func (x *ExportAnimalsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_animals_v1_animals_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportAnimalsRequest.ProtoReflect.Descriptor instead.
+func (*ExportAnimalsRequest) Descriptor() ([]byte, []int) {
+	return file_animals_v1_animals_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ExportAnimalsRequest) GetFilter() *AnimalFilter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+var File_animals_v1_animals_proto protoreflect.FileDescriptor
+
+const file_animals_v1_animals_proto_rawDesc = "" +
+	"\n" +
+	"\x18animals/v1/animals.proto\x12\n" +
+	"animals.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8b\x02\n" +
+	"\x06Animal\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1f\n" +
+	"\vanimal_type\x18\x03 \x01(\tR\n" +
+	"animalType\x12\x14\n" +
+	"\x05breed\x18\x04 \x01(\tR\x05breed\x12\x14\n" +
+	"\x05color\x18\x05 \x01(\tR\x05color\x12\x10\n" +
+	"\x03sex\x18\x06 \x01(\tR\x03sex\x12\x1f\n" +
+	"\vanimal_size\x18\a \x01(\tR\n" +
+	"animalSize\x12>\n" +
+	"\rdate_of_birth\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\vdateOfBirth\x12\x1d\n" +
+	"\n" +
+	"shelter_id\x18\t \x01(\tR\tshelterId\"\xde\x05\n" +
+	"\x06Intake\x12%\n" +
+	"\x0eimpound_number\x18\x01 \x01(\tR\rimpoundNumber\x12#\n" +
+	"\rkennel_number\x18\x02 \x01(\tR\fkennelNumber\x12\x1b\n" +
+	"\tanimal_id\x18\x03 \x01(\tR\banimalId\x12;\n" +
+	"\vintake_date\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"intakeDate\x12=\n" +
+	"\foutcome_date\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\voutcomeDate\x12&\n" +
+	"\x0fdays_in_shelter\x18\x06 \x01(\x05R\rdaysInShelter\x12\x1f\n" +
+	"\vintake_type\x18\a \x01(\tR\n" +
+	"intakeType\x12%\n" +
+	"\x0eintake_subtype\x18\b \x01(\tR\rintakeSubtype\x12!\n" +
+	"\foutcome_type\x18\t \x01(\tR\voutcomeType\x12'\n" +
+	"\x0foutcome_subtype\x18\n" +
+	" \x01(\tR\x0eoutcomeSubtype\x12)\n" +
+	"\x10intake_condition\x18\v \x01(\tR\x0fintakeCondition\x12+\n" +
+	"\x11outcome_condition\x18\f \x01(\tR\x10outcomeCondition\x12/\n" +
+	"\x13intake_jurisdiction\x18\r \x01(\tR\x12intakeJurisdiction\x121\n" +
+	"\x14outcome_jurisdiction\x18\x0e \x01(\tR\x13outcomeJurisdiction\x12\x1a\n" +
+	"\blocation\x18\x0f \x01(\tR\blocation\x12!\n" +
+	"\fanimal_count\x18\x10 \x01(\x05R\vanimalCount\x12\x19\n" +
+	"\bzip_code\x18\x11 \x01(\x05R\azipCode\x12\x1d\n" +
+	"\n" +
+	"shelter_id\x18\x12 \x01(\tR\tshelterId\"\xad\x01\n" +
+	"\fAnimalFilter\x12\x1f\n" +
+	"\vanimal_type\x18\x01 \x01(\tR\n" +
+	"animalType\x12\x14\n" +
+	"\x05breed\x18\x02 \x01(\tR\x05breed\x12\x14\n" +
+	"\x05color\x18\x03 \x01(\tR\x05color\x12\x10\n" +
+	"\x03sex\x18\x04 \x01(\tR\x03sex\x12\x1f\n" +
+	"\vanimal_size\x18\x05 \x01(\tR\n" +
+	"animalSize\x12\x1d\n" +
+	"\n" +
+	"shelter_id\x18\x06 \x01(\tR\tshelterId\"\x82\x01\n" +
+	"\x12ListAnimalsRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x120\n" +
+	"\x06filter\x18\x03 \x01(\v2\x18.animals.v1.AnimalFilterR\x06filter\"k\n" +
+	"\x13ListAnimalsResponse\x12,\n" +
+	"\aanimals\x18\x01 \x03(\v2\x12.animals.v1.AnimalR\aanimals\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"A\n" +
+	"\x10GetAnimalRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1d\n" +
+	"\n" +
+	"shelter_id\x18\x02 \x01(\tR\tshelterId\"\x83\x02\n" +
+	"\x12ListIntakesRequest\x12\x1b\n" +
+	"\tpage_size\x18\x01 \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\x02 \x01(\tR\tpageToken\x12\x1b\n" +
+	"\tanimal_id\x18\x03 \x01(\tR\banimalId\x12\x1f\n" +
+	"\vintake_type\x18\x04 \x01(\tR\n" +
+	"intakeType\x12!\n" +
+	"\foutcome_type\x18\x05 \x01(\tR\voutcomeType\x12\"\n" +
+	"\n" +
+	"in_shelter\x18\x06 \x01(\bH\x00R\tinShelter\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"shelter_id\x18\a \x01(\tR\tshelterIdB\r\n" +
+	"\v_in_shelter\"k\n" +
+	"\x13ListIntakesResponse\x12,\n" +
+	"\aintakes\x18\x01 \x03(\v2\x12.animals.v1.IntakeR\aintakes\x12&\n" +
+	"\x0fnext_page_token\x18\x02 \x01(\tR\rnextPageToken\"H\n" +
+	"\x14ExportAnimalsRequest\x120\n" +
+	"\x06filter\x18\x01 \x01(\v2\x18.animals.v1.AnimalFilterR\x06filter2\xb7\x02\n" +
+	"\rAnimalService\x12N\n" +
+	"\vListAnimals\x12\x1e.animals.v1.ListAnimalsRequest\x1a\x1f.animals.v1.ListAnimalsResponse\x12=\n" +
+	"\tGetAnimal\x12\x1c.animals.v1.GetAnimalRequest\x1a\x12.animals.v1.Animal\x12N\n" +
+	"\vListIntakes\x12\x1e.animals.v1.ListIntakesRequest\x1a\x1f.animals.v1.ListIntakesResponse\x12G\n" +
+	"\rExportAnimals\x12 .animals.v1.ExportAnimalsRequest\x1a\x12.animals.v1.Animal0\x01B;Z9github.com/bbrombacher/animals/proto/animals/v1;animalsv1b\x06proto3"
+
+var (
+	file_animals_v1_animals_proto_rawDescOnce sync.Once
+	file_animals_v1_animals_proto_rawDescData []byte
+)
+
+func file_animals_v1_animals_proto_rawDescGZIP() []byte {
+	file_animals_v1_animals_proto_rawDescOnce.Do(func() {
+		file_animals_v1_animals_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_animals_v1_animals_proto_rawDesc), len(file_animals_v1_animals_proto_rawDesc)))
+	})
+	return file_animals_v1_animals_proto_rawDescData
+}
+
+var file_animals_v1_animals_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_animals_v1_animals_proto_goTypes = []any{
+	(*Animal)(nil),                // 0: animals.v1.Animal
+	(*Intake)(nil),                // 1: animals.v1.Intake
+	(*AnimalFilter)(nil),          // 2: animals.v1.AnimalFilter
+	(*ListAnimalsRequest)(nil),    // 3: animals.v1.ListAnimalsRequest
+	(*ListAnimalsResponse)(nil),   // 4: animals.v1.ListAnimalsResponse
+	(*GetAnimalRequest)(nil),      // 5: animals.v1.GetAnimalRequest
+	(*ListIntakesRequest)(nil),    // 6: animals.v1.ListIntakesRequest
+	(*ListIntakesResponse)(nil),   // 7: animals.v1.ListIntakesResponse
+	(*ExportAnimalsRequest)(nil),  // 8: animals.v1.ExportAnimalsRequest
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+}
+var file_animals_v1_animals_proto_depIdxs = []int32{
+	9,  // 0: animals.v1.Animal.date_of_birth:type_name -> google.protobuf.Timestamp
+	9,  // 1: animals.v1.Intake.intake_date:type_name -> google.protobuf.Timestamp
+	9,  // 2: animals.v1.Intake.outcome_date:type_name -> google.protobuf.Timestamp
+	2,  // 3: animals.v1.ListAnimalsRequest.filter:type_name -> animals.v1.AnimalFilter
+	0,  // 4: animals.v1.ListAnimalsResponse.animals:type_name -> animals.v1.Animal
+	1,  // 5: animals.v1.ListIntakesResponse.intakes:type_name -> animals.v1.Intake
+	2,  // 6: animals.v1.ExportAnimalsRequest.filter:type_name -> animals.v1.AnimalFilter
+	3,  // 7: animals.v1.AnimalService.ListAnimals:input_type -> animals.v1.ListAnimalsRequest
+	5,  // 8: animals.v1.AnimalService.GetAnimal:input_type -> animals.v1.GetAnimalRequest
+	6,  // 9: animals.v1.AnimalService.ListIntakes:input_type -> animals.v1.ListIntakesRequest
+	8,  // 10: animals.v1.AnimalService.ExportAnimals:input_type -> animals.v1.ExportAnimalsRequest
+	4,  // 11: animals.v1.AnimalService.ListAnimals:output_type -> animals.v1.ListAnimalsResponse
+	0,  // 12: animals.v1.AnimalService.GetAnimal:output_type -> animals.v1.Animal
+	7,  // 13: animals.v1.AnimalService.ListIntakes:output_type -> animals.v1.ListIntakesResponse
+	0,  // 14: animals.v1.AnimalService.ExportAnimals:output_type -> animals.v1.Animal
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_animals_v1_animals_proto_init() }
+func file_animals_v1_animals_proto_init() {
+	if File_animals_v1_animals_proto != nil {
+		return
+	}
+	file_animals_v1_animals_proto_msgTypes[6].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_animals_v1_animals_proto_rawDesc), len(file_animals_v1_animals_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_animals_v1_animals_proto_goTypes,
+		DependencyIndexes: file_animals_v1_animals_proto_depIdxs,
+		MessageInfos:      file_animals_v1_animals_proto_msgTypes,
+	}.Build()
+	File_animals_v1_animals_proto = out.File
+	file_animals_v1_animals_proto_goTypes = nil
+	file_animals_v1_animals_proto_depIdxs = nil
+}
@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: animals/v1/animals.proto
+
+package animalsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AnimalService_ListAnimals_FullMethodName   = "/animals.v1.AnimalService/ListAnimals"
+	AnimalService_GetAnimal_FullMethodName     = "/animals.v1.AnimalService/GetAnimal"
+	AnimalService_ListIntakes_FullMethodName   = "/animals.v1.AnimalService/ListIntakes"
+	AnimalService_ExportAnimals_FullMethodName = "/animals.v1.AnimalService/ExportAnimals"
+)
+
+// AnimalServiceClient is the client API for AnimalService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AnimalService exposes the shelter data served by the REST API.
+// Authenticate with an "x-api-key" or "authorization: Bearer <jwt>" header;
+// anonymous callers only see adoptable animals.
+type AnimalServiceClient interface {
+	ListAnimals(ctx context.Context, in *ListAnimalsRequest, opts ...grpc.CallOption) (*ListAnimalsResponse, error)
+	GetAnimal(ctx context.Context, in *GetAnimalRequest, opts ...grpc.CallOption) (*Animal, error)
+	ListIntakes(ctx context.Context, in *ListIntakesRequest, opts ...grpc.CallOption) (*ListIntakesResponse, error)
+	// ExportAnimals streams every animal matching the filter.
+	ExportAnimals(ctx context.Context, in *ExportAnimalsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Animal], error)
+}
+
+type animalServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAnimalServiceClient(cc grpc.ClientConnInterface) AnimalServiceClient {
+	return &animalServiceClient{cc}
+}
+
+func (c *animalServiceClient) ListAnimals(ctx context.Context, in *ListAnimalsRequest, opts ...grpc.CallOption) (*ListAnimalsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAnimalsResponse)
+	err := c.cc.Invoke(ctx, AnimalService_ListAnimals_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *animalServiceClient) GetAnimal(ctx context.Context, in *GetAnimalRequest, opts ...grpc.CallOption) (*Animal, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Animal)
+	err := c.cc.Invoke(ctx, AnimalService_GetAnimal_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *animalServiceClient) ListIntakes(ctx context.Context, in *ListIntakesRequest, opts ...grpc.CallOption) (*ListIntakesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListIntakesResponse)
+	err := c.cc.Invoke(ctx, AnimalService_ListIntakes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *animalServiceClient) ExportAnimals(ctx context.Context, in *ExportAnimalsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Animal], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AnimalService_ServiceDesc.Streams[0], AnimalService_ExportAnimals_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportAnimalsRequest, Animal]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AnimalService_ExportAnimalsClient = grpc.ServerStreamingClient[Animal]
+
+// AnimalServiceServer is the server API for AnimalService service.
+// All implementations must embed UnimplementedAnimalServiceServer
+// for forward compatibility.
+//
+// AnimalService exposes the shelter data served by the REST API.
+// Authenticate with an "x-api-key" or "authorization: Bearer <jwt>" header;
+// anonymous callers only see adoptable animals.
+type AnimalServiceServer interface {
+	ListAnimals(context.Context, *ListAnimalsRequest) (*ListAnimalsResponse, error)
+	GetAnimal(context.Context, *GetAnimalRequest) (*Animal, error)
+	ListIntakes(context.Context, *ListIntakesRequest) (*ListIntakesResponse, error)
+	// ExportAnimals streams every animal matching the filter.
+	ExportAnimals(*ExportAnimalsRequest, grpc.ServerStreamingServer[Animal]) error
+	mustEmbedUnimplementedAnimalServiceServer()
+}
+
+// UnimplementedAnimalServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAnimalServiceServer struct{}
+
+func (UnimplementedAnimalServiceServer) ListAnimals(context.Context, *ListAnimalsRequest) (*ListAnimalsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAnimals not implemented")
+}
+func (UnimplementedAnimalServiceServer) GetAnimal(context.Context, *GetAnimalRequest) (*Animal, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAnimal not implemented")
+}
+func (UnimplementedAnimalServiceServer) ListIntakes(context.Context, *ListIntakesRequest) (*ListIntakesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListIntakes not implemented")
+}
+func (UnimplementedAnimalServiceServer) ExportAnimals(*ExportAnimalsRequest, grpc.ServerStreamingServer[Animal]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportAnimals not implemented")
+}
+func (UnimplementedAnimalServiceServer) mustEmbedUnimplementedAnimalServiceServer() {}
+func (UnimplementedAnimalServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeAnimalServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AnimalServiceServer will
+// result in compilation errors.
+type UnsafeAnimalServiceServer interface {
+	mustEmbedUnimplementedAnimalServiceServer()
+}
+
+func RegisterAnimalServiceServer(s grpc.ServiceRegistrar, srv AnimalServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAnimalServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AnimalService_ServiceDesc, srv)
+}
+
+func _AnimalService_ListAnimals_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAnimalsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnimalServiceServer).ListAnimals(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnimalService_ListAnimals_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnimalServiceServer).ListAnimals(ctx, req.(*ListAnimalsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnimalService_GetAnimal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAnimalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnimalServiceServer).GetAnimal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnimalService_GetAnimal_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnimalServiceServer).GetAnimal(ctx, req.(*GetAnimalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnimalService_ListIntakes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListIntakesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnimalServiceServer).ListIntakes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnimalService_ListIntakes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnimalServiceServer).ListIntakes(ctx, req.(*ListIntakesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnimalService_ExportAnimals_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportAnimalsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AnimalServiceServer).ExportAnimals(m, &grpc.GenericServerStream[ExportAnimalsRequest, Animal]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AnimalService_ExportAnimalsServer = grpc.ServerStreamingServer[Animal]
+
+// AnimalService_ServiceDesc is the grpc.ServiceDesc for AnimalService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AnimalService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "animals.v1.AnimalService",
+	HandlerType: (*AnimalServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListAnimals",
+			Handler:    _AnimalService_ListAnimals_Handler,
+		},
+		{
+			MethodName: "GetAnimal",
+			Handler:    _AnimalService_GetAnimal_Handler,
+		},
+		{
+			MethodName: "ListIntakes",
+			Handler:    _AnimalService_ListIntakes_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportAnimals",
+			Handler:       _AnimalService_ExportAnimals_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "animals/v1/animals.proto",
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/config"
+	"github.com/bbrombacher/animals/ratelimit"
+	"github.com/jmoiron/sqlx"
+)
+
+// rateLimiter holds the buckets shared by the HTTP routes and the gRPC
+// methods.
+type rateLimiter struct {
+	store  ratelimit.Store
+	limits ratelimit.Limits
+	// authLimit is each IP's limit on requests with credentials, taken
+	// before they are checked.
+	authLimit      ratelimit.Limit
+	trustForwarded bool
+}
+
+// authRoute names the bucket authLimit is taken from.
+const authRoute = "auth"
+
+// newRateLimiter builds the rate limiter described by cfg, or returns nil
+// when rate limiting is disabled.
+func newRateLimiter(ctx context.Context, cfg config.RateLimit, db *sqlx.DB) *rateLimiter {
+	var store ratelimit.Store
+	switch cfg.Backend {
+	case "none":
+		return nil
+	case "postgres":
+		store = ratelimit.PostgresStore{DB: db}
+	default:
+		store = ratelimit.NewMemoryStore()
+	}
+	ratelimit.StartJanitor(ctx, store, 10*time.Minute, time.Hour)
+
+	routes, err := ratelimit.ParseRoutes(cfg.Routes)
+	if err != nil {
+		slog.Error("invalid rate limit routes", "error", err)
+		os.Exit(1)
+	}
+	return &rateLimiter{
+		store: store,
+		limits: ratelimit.Limits{
+			Default: ratelimit.Limit{Rate: cfg.Rate, Burst: cfg.Burst},
+			Routes:  routes,
+		},
+		authLimit:      ratelimit.Limit{Rate: cfg.AuthRate, Burst: cfg.AuthBurst},
+		trustForwarded: cfg.TrustForwarded,
+	}
+}
+
+func (r *rateLimiter) middleware() func(http.Handler) http.Handler {
+	return ratelimit.Middleware(r.store, r.limits, routeName, clientKey(r.trustForwarded))
+}
+
+// authMiddleware limits requests with credentials by IP before auth checks
+// them, so a flood of bad API keys can't reach the database unlimited.
+// Requests without credentials pass straight through.
+func (r *rateLimiter) authMiddleware() func(http.Handler) http.Handler {
+	limit := ratelimit.Middleware(r.store, ratelimit.Limits{Default: r.authLimit},
+		func(*http.Request) string { return authRoute }, clientIP(r.trustForwarded))
+	return func(next http.Handler) http.Handler {
+		limited := limit(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if auth.HasCredentials(req) {
+				limited.ServeHTTP(w, req)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// clientKey identifies authenticated callers by principal and everyone else
+// by IP address.
+func clientKey(trustForwarded bool) func(*http.Request) string {
+	ip := clientIP(trustForwarded)
+	return func(req *http.Request) string {
+		if p := auth.FromContext(req.Context()); p != auth.Anonymous {
+			return p.Subject
+		}
+		return ip(req)
+	}
+}
+
+// clientIP identifies callers by IP address. When trustForwarded is set
+// that is the last X-Forwarded-For entry, the one our proxy appended:
+// entries before it come from the client and can be anything.
+func clientIP(trustForwarded bool) func(*http.Request) string {
+	return func(req *http.Request) string {
+		if trustForwarded {
+			if ip := lastForwardedFor(req.Header); ip != "" {
+				return "ip:" + ip
+			}
+		}
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+		return "ip:" + host
+	}
+}
+
+// lastForwardedFor returns the right-most X-Forwarded-For entry, across
+// every copy of the header.
+func lastForwardedFor(h http.Header) string {
+	values := h.Values("X-Forwarded-For")
+	for i := len(values) - 1; i >= 0; i-- {
+		entries := strings.Split(values[i], ",")
+		for j := len(entries) - 1; j >= 0; j-- {
+			if ip := strings.TrimSpace(entries[j]); ip != "" {
+				return ip
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// takeQuery refills and takes from a bucket in one statement so concurrent
+// instances can't both spend the last token. In ON CONFLICT ... SET every
+// reference to rl reads the row as it was before the update.
+const takeQuery = `
+INSERT INTO rate_limits AS rl (key, tokens, allowed, updated_at)
+VALUES ($1, $2 - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+    allowed = LEAST($2, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $3) >= 1,
+    tokens = LEAST($2, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $3)
+        - CASE WHEN LEAST($2, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $3) >= 1 THEN 1 ELSE 0 END,
+    updated_at = now()
+RETURNING tokens, allowed`
+
+// PostgresStore keeps buckets in the rate_limits table so every instance
+// shares the same limits.
+type PostgresStore struct {
+	DB *sqlx.DB
+}
+
+// Take implements Store.
+func (p PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	var row struct {
+		Tokens  float64 `db:"tokens"`
+		Allowed bool    `db:"allowed"`
+	}
+	if err := p.DB.GetContext(ctx, &row, takeQuery, key, limit.Burst, limit.Rate); err != nil {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return result(row.Allowed, row.Tokens, limit), nil
+}
+
+// Prune implements Store.
+func (p PostgresStore) Prune(ctx context.Context, idle time.Duration) error {
+	_, err := p.DB.ExecContext(ctx,
+		"DELETE FROM rate_limits WHERE updated_at < now() - make_interval(secs => $1)",
+		idle.Seconds())
+	return err
+}
@@ -0,0 +1,163 @@
+// Package ratelimit implements token bucket rate limiting for the API, with
+// an in-memory store for single instances and a Postgres store shared by
+// every instance.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bbrombacher/animals/logging"
+)
+
+// Limit is a token bucket: Burst tokens, refilled at Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking one token.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, 0 when allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets.
+type Store interface {
+	// Take removes one token from key's bucket if there is one.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Prune drops buckets untouched for longer than idle.
+	Prune(ctx context.Context, idle time.Duration) error
+}
+
+// Limits holds the default limit and per-route overrides.
+type Limits struct {
+	Default Limit
+	Routes  map[string]Limit
+}
+
+// For returns the limit for route.
+func (l Limits) For(route string) Limit {
+	if limit, ok := l.Routes[route]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// ParseRoutes parses per-route overrides written as
+// "route=rate:burst,route=rate:burst", e.g. "/v1/go-animals=5:10".
+func ParseRoutes(s string) (map[string]Limit, error) {
+	routes := map[string]Limit{}
+	if strings.TrimSpace(s) == "" {
+		return routes, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		route, spec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("route limit %q must look like route=rate:burst", entry)
+		}
+		rateStr, burstStr, ok := strings.Cut(spec, ":")
+		if !ok {
+			return nil, fmt.Errorf("route limit %q must look like route=rate:burst", entry)
+		}
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("route limit %q has an invalid rate", entry)
+		}
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("route limit %q has an invalid burst", entry)
+		}
+		routes[route] = Limit{Rate: rate, Burst: burst}
+	}
+	return routes, nil
+}
+
+// Middleware limits each client per route. routeOf names the route and
+// clientOf identifies the caller, e.g. by API key or IP. If the store fails
+// the request is let through so a limiter outage doesn't become an API
+// outage.
+func Middleware(store Store, limits Limits, routeOf, clientOf func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			route := routeOf(req)
+			limit := limits.For(route)
+
+			res, err := store.Take(req.Context(), route+"|"+clientOf(req), limit)
+			if err != nil {
+				logging.FromContext(req.Context()).Error("rate limit check failed, allowing request", "error", err)
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			window := int(math.Ceil(float64(limit.Burst) / limit.Rate))
+			h := w.Header()
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, window))
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				h.Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprintln(w, `{"error":"rate limit exceeded"}`)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// StartJanitor prunes buckets idle for longer than idle every interval until
+// ctx is done.
+func StartJanitor(ctx context.Context, store Store, interval, idle time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := store.Prune(ctx, idle); err != nil {
+					slog.Error("failed to prune rate limit buckets", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// result builds a Result from the tokens left after a take.
+func result(allowed bool, tokens float64, limit Limit) Result {
+	res := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	return res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
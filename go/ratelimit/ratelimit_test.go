@@ -0,0 +1,227 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bbrombacher/animals/testdb"
+)
+
+func TestResult(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 10}
+	cases := []struct {
+		name    string
+		allowed bool
+		tokens  float64
+		want    Result
+	}{
+		{name: "full", allowed: true, tokens: 10, want: Result{Allowed: true, Remaining: 10}},
+		{name: "one taken", allowed: true, tokens: 9, want: Result{Allowed: true, Remaining: 9, Reset: 500 * time.Millisecond}},
+		{name: "part token rounds down", allowed: true, tokens: 2.5, want: Result{Allowed: true, Remaining: 2, Reset: 3750 * time.Millisecond}},
+		{name: "empty", allowed: true, tokens: 0, want: Result{Allowed: true, Reset: 5 * time.Second}},
+		{name: "denied", tokens: 0.5, want: Result{Reset: 4750 * time.Millisecond, RetryAfter: 250 * time.Millisecond}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := result(tc.allowed, tc.tokens, limit); got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	// a token a second, three at once
+	limit := Limit{Rate: 1, Burst: 3}
+	steps := []struct {
+		name          string
+		after         time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{name: "first take", wantAllowed: true, wantRemaining: 2},
+		{name: "second", wantAllowed: true, wantRemaining: 1},
+		{name: "third", wantAllowed: true, wantRemaining: 0},
+		{name: "empty", wantRetry: time.Second},
+		{name: "half refilled", after: 500 * time.Millisecond, wantRetry: 500 * time.Millisecond},
+		{name: "refilled", after: 500 * time.Millisecond, wantAllowed: true, wantRemaining: 0},
+		{name: "refill stops at the burst", after: time.Hour, wantAllowed: true, wantRemaining: 2},
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	for _, step := range steps {
+		now = now.Add(step.after)
+		res, err := store.Take(context.Background(), "route|ip:1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != step.wantAllowed || res.Remaining != step.wantRemaining || res.RetryAfter != step.wantRetry {
+			t.Errorf("%s: got %+v, want allowed %t, %d remaining, retry after %v",
+				step.name, res, step.wantAllowed, step.wantRemaining, step.wantRetry)
+		}
+	}
+
+	// buckets are kept per key
+	if res, _ := store.Take(context.Background(), "route|ip:2", limit); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("got %+v for another client", res)
+	}
+
+	now = now.Add(2 * time.Hour)
+	store.Take(context.Background(), "route|ip:3", limit)
+	if err := store.Prune(context.Background(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.buckets) != 1 || store.buckets["route|ip:3"] == nil {
+		t.Errorf("got buckets %v after pruning, want only route|ip:3", store.buckets)
+	}
+}
+
+func TestPostgresStoreTake(t *testing.T) {
+	db := testdb.New(t)
+	store := PostgresStore{DB: db.DB}
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+
+	steps := []struct {
+		name          string
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{name: "first take", wantAllowed: true, wantRemaining: 1},
+		{name: "second", wantAllowed: true, wantRemaining: 0},
+		{name: "empty"},
+	}
+	for _, step := range steps {
+		res, err := store.Take(ctx, "route|ip:1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != step.wantAllowed || res.Remaining != step.wantRemaining {
+			t.Errorf("%s: got %+v, want allowed %t and %d remaining", step.name, res, step.wantAllowed, step.wantRemaining)
+		}
+		if !res.Allowed && (res.RetryAfter <= 0 || res.RetryAfter > time.Second) {
+			t.Errorf("%s: got retry after %v, want at most a second", step.name, res.RetryAfter)
+		}
+	}
+
+	// an hour later the bucket is full again, but no fuller
+	if _, err := db.Exec("UPDATE rate_limits SET updated_at = updated_at - interval '1 hour' WHERE key = 'route|ip:1'"); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := store.Take(ctx, "route|ip:1", limit); err != nil || !res.Allowed || res.Remaining != 1 {
+		t.Errorf("got %+v, %v after refilling", res, err)
+	}
+
+	if _, err := db.Exec("UPDATE rate_limits SET updated_at = now() - interval '2 hours' WHERE key = 'route|ip:1'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Take(ctx, "route|ip:2", limit); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Prune(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	if err := db.Select(&keys, "SELECT key FROM rate_limits"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "route|ip:2" {
+		t.Errorf("got keys %v after pruning, want route|ip:2", keys)
+	}
+}
+
+// fixedStore answers every Take with res, or fails with err.
+type fixedStore struct {
+	res  Result
+	err  error
+	keys []string
+}
+
+func (s *fixedStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.keys = append(s.keys, key)
+	return s.res, s.err
+}
+
+func (s *fixedStore) Prune(ctx context.Context, idle time.Duration) error {
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	limits := Limits{
+		Default: Limit{Rate: 10, Burst: 20},
+		Routes:  map[string]Limit{"/v1/slow": {Rate: 0.5, Burst: 3}},
+	}
+	cases := []struct {
+		name       string
+		route      string
+		store      *fixedStore
+		wantStatus int
+		// wantHeaders are compared when set; "" means absent
+		wantHeaders map[string]string
+	}{
+		{name: "allowed", route: "/v1/fast",
+			store:      &fixedStore{res: Result{Allowed: true, Remaining: 19, Reset: 100 * time.Millisecond}},
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Policy": "20;w=2", "RateLimit-Limit": "20", "RateLimit-Remaining": "19",
+				"RateLimit-Reset": "1", "Retry-After": ""}},
+		{name: "route override", route: "/v1/slow",
+			store:      &fixedStore{res: Result{Allowed: true, Remaining: 2, Reset: 2 * time.Second}},
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Policy": "3;w=6", "RateLimit-Limit": "3", "RateLimit-Remaining": "2",
+				"RateLimit-Reset": "2"}},
+		{name: "denied", route: "/v1/slow",
+			store:      &fixedStore{res: Result{Remaining: 0, Reset: 6 * time.Second, RetryAfter: 1500 * time.Millisecond}},
+			wantStatus: http.StatusTooManyRequests,
+			wantHeaders: map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "6", "Retry-After": "2",
+				"Content-Type": "application/json"}},
+		{name: "store down lets requests through", route: "/v1/fast",
+			store:       &fixedStore{err: errors.New("connection refused")},
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Limit": "", "Retry-After": ""}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			routeOf := func(*http.Request) string { return tc.route }
+			clientOf := func(*http.Request) string { return "ip:1" }
+			handler := Middleware(tc.store, limits, routeOf, clientOf)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.route, nil))
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tc.wantStatus)
+			}
+			for name, want := range tc.wantHeaders {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("got %s %q, want %q", name, got, want)
+				}
+			}
+			if len(tc.store.keys) != 1 || tc.store.keys[0] != tc.route+"|ip:1" {
+				t.Errorf("took from %v", tc.store.keys)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" /v1/go-animals=5:10, /graphql=0.5:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes["/v1/go-animals"] != (Limit{Rate: 5, Burst: 10}) || routes["/graphql"] != (Limit{Rate: 0.5, Burst: 2}) {
+		t.Errorf("got %v", routes)
+	}
+	for _, bad := range []string{"/v1", "/v1=5", "/v1=x:1", "/v1=0:1", "/v1=1:0"} {
+		if _, err := ParseRoutes(bad); err == nil {
+			t.Errorf("got no error for %q", bad)
+		}
+	}
+}
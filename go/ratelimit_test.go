@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/ratelimit"
+	"google.golang.org/grpc/metadata"
+)
+
+// rejectAll fails every credential it is shown, counting the checks.
+type rejectAll struct {
+	checked int
+}
+
+func (a *rejectAll) Authenticate(req *http.Request) (auth.Principal, error) {
+	if !auth.HasCredentials(req) {
+		return auth.Principal{}, auth.ErrNoCredentials
+	}
+	a.checked++
+	return auth.Principal{}, fmt.Errorf("%w: unknown api key", auth.ErrInvalidCredentials)
+}
+
+func TestAuthRateLimit(t *testing.T) {
+	limiter := &rateLimiter{
+		store:     ratelimit.NewMemoryStore(),
+		limits:    ratelimit.Limits{Default: ratelimit.Limit{Rate: 100, Burst: 100}},
+		authLimit: ratelimit.Limit{Rate: 0.001, Burst: 2},
+	}
+	authenticator := &rejectAll{}
+	handler := limiter.authMiddleware()(auth.Middleware(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	steps := []struct {
+		name       string
+		ip         string
+		key        string
+		wantStatus int
+	}{
+		{name: "first bad key", ip: "192.0.2.1", key: "bad", wantStatus: http.StatusUnauthorized},
+		{name: "second bad key", ip: "192.0.2.1", key: "bad", wantStatus: http.StatusUnauthorized},
+		{name: "limited before auth", ip: "192.0.2.1", key: "bad", wantStatus: http.StatusTooManyRequests},
+		{name: "no credentials aren't limited", ip: "192.0.2.1", wantStatus: http.StatusOK},
+		{name: "other ips aren't limited", ip: "192.0.2.2", key: "bad", wantStatus: http.StatusUnauthorized},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(http.MethodGet, "/v1/go-animals", nil)
+		req.RemoteAddr = step.ip + ":1234"
+		if step.key != "" {
+			req.Header.Set(auth.APIKeyHeader, step.key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != step.wantStatus {
+			t.Errorf("%s: got status %d, want %d", step.name, rec.Code, step.wantStatus)
+		}
+	}
+	if authenticator.checked != 3 {
+		t.Errorf("checked %d keys, want 3", authenticator.checked)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name           string
+		trustForwarded bool
+		forwarded      []string
+		want           string
+	}{
+		{name: "remote address", want: "ip:192.0.2.1"},
+		{name: "forwarded ignored unless trusted", forwarded: []string{"203.0.113.7"}, want: "ip:192.0.2.1"},
+		{name: "proxy's entry", trustForwarded: true, forwarded: []string{"203.0.113.7"}, want: "ip:203.0.113.7"},
+		// the client can send any entries before the one the proxy appends
+		{name: "spoofed entries", trustForwarded: true, forwarded: []string{"198.51.100.1, 198.51.100.2, 203.0.113.7"}, want: "ip:203.0.113.7"},
+		{name: "spoofed header", trustForwarded: true, forwarded: []string{"198.51.100.1", "203.0.113.7"}, want: "ip:203.0.113.7"},
+		{name: "trailing comma", trustForwarded: true, forwarded: []string{"203.0.113.7, "}, want: "ip:203.0.113.7"},
+		{name: "no header", trustForwarded: true, want: "ip:192.0.2.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/go-animals", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for _, fwd := range tc.forwarded {
+				req.Header.Add("X-Forwarded-For", fwd)
+			}
+			if got := clientIP(tc.trustForwarded)(req); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGRPCClientIPIgnoresSpoofedEntries(t *testing.T) {
+	key := func(forwarded string) string {
+		md := metadata.Pairs("x-forwarded-for", forwarded)
+		return clientIP(true)(grpcHTTPRequest(context.Background(), "/animals.v1.AnimalService/ListAnimals", md))
+	}
+	if a, b := key("198.51.100.1, 203.0.113.7"), key("198.51.100.2, 203.0.113.7"); a != b || a != "ip:203.0.113.7" {
+		t.Errorf("got keys %q and %q, want ip:203.0.113.7 for both", a, b)
+	}
+}
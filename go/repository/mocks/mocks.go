@@ -0,0 +1,179 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: repository.go
+//
+// Generated by this command:
+//
+//	mockgen -source=repository.go -destination=mocks/mocks.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	repository "github.com/bbrombacher/animals/repository"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAnimalRepository is a mock of AnimalRepository interface.
+type MockAnimalRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAnimalRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockAnimalRepositoryMockRecorder is the mock recorder for MockAnimalRepository.
+type MockAnimalRepositoryMockRecorder struct {
+	mock *MockAnimalRepository
+}
+
+// NewMockAnimalRepository creates a new mock instance.
+func NewMockAnimalRepository(ctrl *gomock.Controller) *MockAnimalRepository {
+	mock := &MockAnimalRepository{ctrl: ctrl}
+	mock.recorder = &MockAnimalRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAnimalRepository) EXPECT() *MockAnimalRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockAnimalRepository) Get(ctx context.Context, shelterID, id string, adoptableOnly bool) (repository.Animal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, shelterID, id, adoptableOnly)
+	ret0, _ := ret[0].(repository.Animal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockAnimalRepositoryMockRecorder) Get(ctx, shelterID, id, adoptableOnly any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockAnimalRepository)(nil).Get), ctx, shelterID, id, adoptableOnly)
+}
+
+// List mocks base method.
+func (m *MockAnimalRepository) List(ctx context.Context, q repository.AnimalQuery) ([]repository.Animal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, q)
+	ret0, _ := ret[0].([]repository.Animal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAnimalRepositoryMockRecorder) List(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAnimalRepository)(nil).List), ctx, q)
+}
+
+// Stream mocks base method.
+func (m *MockAnimalRepository) Stream(ctx context.Context, q repository.AnimalQuery, fn func(repository.Animal) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stream", ctx, q, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stream indicates an expected call of Stream.
+func (mr *MockAnimalRepositoryMockRecorder) Stream(ctx, q, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stream", reflect.TypeOf((*MockAnimalRepository)(nil).Stream), ctx, q, fn)
+}
+
+// MockIntakeRepository is a mock of IntakeRepository interface.
+type MockIntakeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIntakeRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockIntakeRepositoryMockRecorder is the mock recorder for MockIntakeRepository.
+type MockIntakeRepositoryMockRecorder struct {
+	mock *MockIntakeRepository
+}
+
+// NewMockIntakeRepository creates a new mock instance.
+func NewMockIntakeRepository(ctrl *gomock.Controller) *MockIntakeRepository {
+	mock := &MockIntakeRepository{ctrl: ctrl}
+	mock.recorder = &MockIntakeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIntakeRepository) EXPECT() *MockIntakeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockIntakeRepository) List(ctx context.Context, q repository.IntakeQuery) ([]repository.Intake, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, q)
+	ret0, _ := ret[0].([]repository.Intake)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockIntakeRepositoryMockRecorder) List(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockIntakeRepository)(nil).List), ctx, q)
+}
+
+// Stats mocks base method.
+func (m *MockIntakeRepository) Stats(ctx context.Context, q repository.IntakeQuery) (repository.IntakeStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", ctx, q)
+	ret0, _ := ret[0].(repository.IntakeStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockIntakeRepositoryMockRecorder) Stats(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockIntakeRepository)(nil).Stats), ctx, q)
+}
+
+// MockHistoryRepository is a mock of HistoryRepository interface.
+type MockHistoryRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockHistoryRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockHistoryRepositoryMockRecorder is the mock recorder for MockHistoryRepository.
+type MockHistoryRepositoryMockRecorder struct {
+	mock *MockHistoryRepository
+}
+
+// NewMockHistoryRepository creates a new mock instance.
+func NewMockHistoryRepository(ctrl *gomock.Controller) *MockHistoryRepository {
+	mock := &MockHistoryRepository{ctrl: ctrl}
+	mock.recorder = &MockHistoryRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHistoryRepository) EXPECT() *MockHistoryRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AnimalHistory mocks base method.
+func (m *MockHistoryRepository) AnimalHistory(ctx context.Context, key repository.AnimalKey) ([]repository.Change, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnimalHistory", ctx, key)
+	ret0, _ := ret[0].([]repository.Change)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnimalHistory indicates an expected call of AnimalHistory.
+func (mr *MockHistoryRepositoryMockRecorder) AnimalHistory(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnimalHistory", reflect.TypeOf((*MockHistoryRepository)(nil).AnimalHistory), ctx, key)
+}
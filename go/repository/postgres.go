@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/bbrombacher/animals/tracing"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "animals_db_query_duration_seconds",
+	Help:    "Database query latency by query name, excluding pool wait.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"query"})
+
+// adoptableClause limits animals to those still in the shelter, i.e. with an
+// intake that has no outcome yet.
+var adoptableClause = sq.Expr("EXISTS (SELECT 1 FROM animal_intake i WHERE i.shelter_id = animals.shelter_id AND i.animal_id = animals.id AND i.outcome_date IS NULL AND i.deleted_at IS NULL)")
+
+// adoptableIntakeClause limits intakes to those of adoptable animals.
+var adoptableIntakeClause = sq.Expr("EXISTS (SELECT 1 FROM animal_intake i WHERE i.shelter_id = animal_intake.shelter_id AND i.animal_id = animal_intake.animal_id AND i.outcome_date IS NULL AND i.deleted_at IS NULL)")
+
+// The columns read into Animal and Intake. Tables may have more, such as
+// deleted_at.
+var (
+	animalColumns = columnsOf(Animal{})
+	intakeColumns = columnsOf(Intake{})
+)
+
+// PostgresAnimals reads animals from Postgres.
+type PostgresAnimals struct {
+	DB *sqlx.DB
+}
+
+var _ AnimalRepository = PostgresAnimals{}
+
+func (p PostgresAnimals) List(ctx context.Context, q AnimalQuery) ([]Animal, error) {
+	var rows []Animal
+	err := selectNamed(ctx, p.DB, "list_animals", animalsQuery(q), &rows)
+	return rows, err
+}
+
+func (p PostgresAnimals) Get(ctx context.Context, shelterID, id string, adoptableOnly bool) (Animal, error) {
+	var rows []Animal
+	// two rows are enough to tell an ambiguous id
+	q := AnimalQuery{ShelterID: shelterID, IDs: []string{id}, AdoptableOnly: adoptableOnly, Limit: 2}
+	if err := selectNamed(ctx, p.DB, "get_animal", animalsQuery(q), &rows); err != nil {
+		return Animal{}, err
+	}
+	switch len(rows) {
+	case 0:
+		return Animal{}, ErrNotFound
+	case 1:
+		return rows[0], nil
+	default:
+		return Animal{}, ErrAmbiguous
+	}
+}
+
+func (p PostgresAnimals) Stream(ctx context.Context, q AnimalQuery, fn func(Animal) error) (err error) {
+	sqlQuery, args, err := animalsQuery(q).ToSql()
+	if err != nil {
+		return err
+	}
+
+	conn, err := tracing.Connx(ctx, p.DB)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	count := 0
+	start := time.Now()
+	queryCtx, span := tracing.StartQuery(ctx, "stream_animals", sqlQuery)
+	defer func() {
+		tracing.EndQuery(span, count, err)
+		observeQuery("stream_animals", start)
+	}()
+
+	rows, err := conn.QueryxContext(queryCtx, sqlQuery, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var animal Animal
+		if err = rows.StructScan(&animal); err != nil {
+			return err
+		}
+		if err = fn(animal); err != nil {
+			return err
+		}
+		count++
+	}
+	return rows.Err()
+}
+
+func animalsQuery(q AnimalQuery) sq.SelectBuilder {
+	query := sq.Select(animalColumns...).From("animals").OrderBy("shelter_id", "id").PlaceholderFormat(sq.Dollar)
+	if !q.IncludeDeleted {
+		query = query.Where(sq.Eq{"deleted_at": nil})
+	}
+	if len(q.IDs) > 0 {
+		query = query.Where(sq.Eq{"id": q.IDs})
+	}
+	query = query.Where(eqIfSet(map[string]string{
+		"shelter_id":  q.ShelterID,
+		"animal_type": q.AnimalType,
+		"breed":       q.Breed,
+		"color":       q.Color,
+		"sex":         q.Sex,
+		"animal_size": q.AnimalSize,
+	}))
+	if q.AdoptableOnly {
+		query = query.Where(adoptableClause)
+	}
+	if q.After != nil {
+		query = query.Where(sq.Expr("(shelter_id, id) > (?, ?)", q.After.ShelterID, q.After.ID))
+	}
+	if q.Limit > 0 {
+		query = query.Limit(uint64(q.Limit))
+	}
+	return query
+}
+
+// PostgresIntakes reads intakes from Postgres.
+type PostgresIntakes struct {
+	DB *sqlx.DB
+}
+
+var _ IntakeRepository = PostgresIntakes{}
+
+func (p PostgresIntakes) List(ctx context.Context, q IntakeQuery) ([]Intake, error) {
+	query := intakeFilter(sq.Select(intakeColumns...).From("animal_intake"), q).
+		OrderBy("shelter_id", "animal_id", "kennel_number", "impound_number")
+	if q.After != nil {
+		query = query.Where(sq.Expr("(shelter_id, animal_id, kennel_number, impound_number) > (?, ?, ?, ?)",
+			q.After.ShelterID, q.After.AnimalID, q.After.KennelNumber, q.After.ImpoundNumber))
+	}
+	if q.Limit > 0 {
+		query = query.Limit(uint64(q.Limit))
+	}
+
+	var rows []Intake
+	err := selectNamed(ctx, p.DB, "list_intakes", query, &rows)
+	return rows, err
+}
+
+func (p PostgresIntakes) Stats(ctx context.Context, q IntakeQuery) (IntakeStats, error) {
+	base := intakeFilter(sq.Select().From("animal_intake"), q)
+
+	var totals []struct {
+		Animals int      `db:"animals"`
+		Intakes int      `db:"intakes"`
+		AvgDays *float64 `db:"avg_days"`
+	}
+	totalsQuery := base.Columns("COUNT(DISTINCT (shelter_id, animal_id)) AS animals", "COUNT(*) AS intakes", "AVG(days_in_shelter) AS avg_days")
+	if err := selectNamed(ctx, p.DB, "intake_stats", totalsQuery, &totals); err != nil {
+		return IntakeStats{}, err
+	}
+
+	stats := IntakeStats{}
+	if len(totals) > 0 {
+		stats.Animals, stats.Intakes, stats.AverageDaysInShelter = totals[0].Animals, totals[0].Intakes, totals[0].AvgDays
+	}
+	for column, dest := range map[string]*[]ValueCount{
+		"intake_type":  &stats.ByIntakeType,
+		"outcome_type": &stats.ByOutcomeType,
+	} {
+		countQuery := base.Columns(column+" AS value", "COUNT(*) AS count").GroupBy(column).OrderBy("count DESC", "value")
+		var counts []struct {
+			Value string `db:"value"`
+			Count int    `db:"count"`
+		}
+		if err := selectNamed(ctx, p.DB, "intake_stats_by_"+column, countQuery, &counts); err != nil {
+			return IntakeStats{}, err
+		}
+		for _, c := range counts {
+			*dest = append(*dest, ValueCount{Value: c.Value, Count: c.Count})
+		}
+	}
+	return stats, nil
+}
+
+// PostgresHistory reads the animal_history and intake_history tables.
+type PostgresHistory struct {
+	DB *sqlx.DB
+}
+
+var _ HistoryRepository = PostgresHistory{}
+
+func (p PostgresHistory) AnimalHistory(ctx context.Context, key AnimalKey) ([]Change, error) {
+	query := sq.Expr(`SELECT entity, shelter_id, animal_id, kennel_number, impound_number,
+			operation, field, old_value, new_value, source, changed_at
+		FROM (
+			SELECT 'animal' AS entity, shelter_id, animal_id, '' AS kennel_number, '' AS impound_number,
+				operation, field, old_value, new_value, source, changed_at, id
+			FROM animal_history WHERE shelter_id = $1 AND animal_id = $2
+			UNION ALL
+			SELECT 'intake', shelter_id, animal_id, kennel_number, impound_number,
+				operation, field, old_value, new_value, source, changed_at, id
+			FROM intake_history WHERE shelter_id = $1 AND animal_id = $2
+		) history
+		ORDER BY changed_at, entity, id`, key.ShelterID, key.ID)
+
+	var rows []Change
+	err := selectNamed(ctx, p.DB, "animal_history", query, &rows)
+	return rows, err
+}
+
+// intakeFilter applies q's conditions, but not its paging, to query.
+func intakeFilter(query sq.SelectBuilder, q IntakeQuery) sq.SelectBuilder {
+	query = query.PlaceholderFormat(sq.Dollar).Where(sq.Eq{"deleted_at": nil})
+	if len(q.AnimalIDs) > 0 {
+		query = query.Where(sq.Eq{"animal_id": q.AnimalIDs})
+	}
+	query = query.Where(eqIfSet(map[string]string{
+		"shelter_id":   q.ShelterID,
+		"intake_type":  q.IntakeType,
+		"outcome_type": q.OutcomeType,
+	}))
+	if q.IntakeFrom != nil {
+		query = query.Where(sq.GtOrEq{"intake_date": *q.IntakeFrom})
+	}
+	if q.IntakeTo != nil {
+		query = query.Where(sq.Lt{"intake_date": *q.IntakeTo})
+	}
+	if q.InShelter != nil {
+		if *q.InShelter {
+			query = query.Where(sq.Eq{"outcome_date": nil})
+		} else {
+			query = query.Where(sq.NotEq{"outcome_date": nil})
+		}
+	}
+	if q.AdoptableOnly {
+		query = query.Where(adoptableIntakeClause)
+	}
+	return query
+}
+
+// columnsOf lists the db tags of a row struct's fields.
+func columnsOf(row interface{}) []string {
+	t := reflect.TypeOf(row)
+	columns := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if column := t.Field(i).Tag.Get("db"); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// eqIfSet builds an equality filter from the non-empty values.
+func eqIfSet(values map[string]string) sq.Eq {
+	eq := sq.Eq{}
+	for column, value := range values {
+		if value != "" {
+			eq[column] = value
+		}
+	}
+	return eq
+}
+
+// selectNamed runs query into the slice dest on a pooled connection,
+// tracing and timing it under name.
+func selectNamed(ctx context.Context, db *sqlx.DB, name string, query sq.Sqlizer, dest interface{}) error {
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	conn, err := tracing.Connx(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	queryStart := time.Now()
+	queryCtx, span := tracing.StartQuery(ctx, name, sqlQuery)
+	err = conn.SelectContext(queryCtx, dest, sqlQuery, args...)
+	rows := 0
+	if v := reflect.ValueOf(dest).Elem(); v.Kind() == reflect.Slice {
+		rows = v.Len()
+	}
+	tracing.EndQuery(span, rows, err)
+	observeQuery(name, queryStart)
+	return err
+}
+
+// observeQuery records the time since start against the named query.
+func observeQuery(name string, start time.Time) {
+	queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+}
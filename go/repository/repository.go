@@ -0,0 +1,230 @@
+// Package repository reads animals and their shelter intakes. The HTTP,
+// GraphQL and gRPC APIs depend only on the interfaces here, so they can be
+// tested against the generated mocks in repository/mocks.
+package repository
+
+//go:generate go tool mockgen -source=repository.go -destination=mocks/mocks.go -package=mocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when a single row was asked for and none matched.
+var ErrNotFound = errors.New("not found")
+
+// ChangeSourceSetting is the Postgres setting the history triggers read to
+// attribute a change, e.g. "etl_run:42" or "user:apikey:alice". Writers set
+// it with set_config on the connection or transaction making the change;
+// changes without it are put down to the database user.
+const ChangeSourceSetting = "animals.change_source"
+
+// ErrAmbiguous is returned when a single row was asked for without its
+// shelter and more than one shelter has a match.
+var ErrAmbiguous = errors.New("matches more than one shelter")
+
+// Shelter is a row of the shelters table: an organisation whose data we
+// load. Animal ids are only unique within a shelter.
+type Shelter struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+}
+
+// Animal is a row of the animals table.
+type Animal struct {
+	ShelterID   string     `db:"shelter_id"`
+	ID          string     `db:"id"`
+	AnimalName  string     `db:"animal_name"`
+	AnimalType  string     `db:"animal_type"`
+	Breed       string     `db:"breed"`
+	Color       string     `db:"color"`
+	Sex         string     `db:"sex"`
+	AnimalSize  string     `db:"animal_size"`
+	DateOfBirth *time.Time `db:"date_of_birth"`
+}
+
+// Key returns the animal's primary key, which orders animal pages.
+func (a Animal) Key() AnimalKey {
+	return AnimalKey{ShelterID: a.ShelterID, ID: a.ID}
+}
+
+// Intake is a row of the animal_intake table: one stay of an animal in the
+// shelter.
+type Intake struct {
+	ShelterID           string     `db:"shelter_id"`
+	ImpoundNumber       string     `db:"impound_number"`
+	KennelNumber        string     `db:"kennel_number"`
+	AnimalID            string     `db:"animal_id"`
+	IntakeDate          *time.Time `db:"intake_date"`
+	OutcomeDate         *time.Time `db:"outcome_date"`
+	DaysInShelter       int        `db:"days_in_shelter"`
+	IntakeType          string     `db:"intake_type"`
+	IntakeSubtype       string     `db:"intake_subtype"`
+	OutcomeType         string     `db:"outcome_type"`
+	OutcomeSubtype      string     `db:"outcome_subtype"`
+	IntakeCondition     string     `db:"intake_condition"`
+	OutcomeCondition    string     `db:"outcome_condition"`
+	IntakeJurisdiction  string     `db:"intake_jurisdiction"`
+	OutcomeJurisdiction string     `db:"outcome_jurisdiction"`
+	Location            string     `db:"location"`
+	AnimalCount         int        `db:"animal_count"`
+	ZipCode             int        `db:"zip_code"`
+}
+
+// Key returns the intake's primary key, which orders intake pages.
+func (i Intake) Key() IntakeKey {
+	return IntakeKey{ShelterID: i.ShelterID, AnimalID: i.AnimalID, KennelNumber: i.KennelNumber, ImpoundNumber: i.ImpoundNumber}
+}
+
+// AnimalKey returns the key of the intake's animal.
+func (i Intake) AnimalKey() AnimalKey {
+	return AnimalKey{ShelterID: i.ShelterID, ID: i.AnimalID}
+}
+
+// IntakeKey is the primary key of an intake.
+type IntakeKey struct {
+	ShelterID     string
+	AnimalID      string
+	KennelNumber  string
+	ImpoundNumber string
+}
+
+// AnimalKey is the primary key of an animal.
+type AnimalKey struct {
+	ShelterID string
+	ID        string
+}
+
+// AnimalQuery selects animals ordered by key. Empty fields match anything.
+type AnimalQuery struct {
+	ShelterID  string
+	IDs        []string
+	AnimalType string
+	Breed      string
+	Color      string
+	Sex        string
+	AnimalSize string
+	// AdoptableOnly keeps animals still in the shelter, i.e. with an intake
+	// that has no outcome yet. Public callers only see these.
+	AdoptableOnly bool
+	// IncludeDeleted keeps animals the ETL soft deleted after they vanished
+	// from the source. They are hidden otherwise.
+	IncludeDeleted bool
+	// After starts the results after this key.
+	After *AnimalKey
+	// Limit caps the rows returned, 0 for no limit.
+	Limit int
+}
+
+// IntakeQuery selects intakes ordered by key. Empty fields match anything,
+// and soft deleted intakes are never matched.
+type IntakeQuery struct {
+	ShelterID   string
+	AnimalIDs   []string
+	IntakeType  string
+	OutcomeType string
+	// IntakeFrom and IntakeTo bound intake_date, the end exclusive.
+	IntakeFrom *time.Time
+	IntakeTo   *time.Time
+	// InShelter keeps intakes without (true) or with (false) an outcome.
+	InShelter *bool
+	// AdoptableOnly keeps intakes of adoptable animals.
+	AdoptableOnly bool
+	// After starts the results after this key.
+	After *IntakeKey
+	// Limit caps the rows returned, 0 for no limit.
+	Limit int
+}
+
+// IntakeStats summarises the intakes matching a query.
+type IntakeStats struct {
+	Animals              int
+	Intakes              int
+	AverageDaysInShelter *float64
+	ByIntakeType         []ValueCount
+	ByOutcomeType        []ValueCount
+}
+
+// ValueCount is how many intakes have a column set to Value.
+type ValueCount struct {
+	Value string
+	Count int
+}
+
+// Change is one audited change to an animal or one of its intakes. Inserts
+// and deletes have no Field and hold the whole row in OldValue or NewValue;
+// updates hold one changed field.
+type Change struct {
+	// Entity is "animal" or "intake".
+	Entity    string `db:"entity"`
+	ShelterID string `db:"shelter_id"`
+	AnimalID  string `db:"animal_id"`
+	// KennelNumber and ImpoundNumber identify the intake of intake changes.
+	KennelNumber  string    `db:"kennel_number"`
+	ImpoundNumber string    `db:"impound_number"`
+	Operation     string    `db:"operation"`
+	Field         *string   `db:"field"`
+	OldValue      RawJSON   `db:"old_value"`
+	NewValue      RawJSON   `db:"new_value"`
+	Source        string    `db:"source"`
+	ChangedAt     time.Time `db:"changed_at"`
+}
+
+// RawJSON is a jsonb column, passed through to JSON responses as is.
+type RawJSON []byte
+
+func (j *RawJSON) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(RawJSON(nil), v...)
+	case string:
+		*j = RawJSON(v)
+	default:
+		return fmt.Errorf("can't scan %T into RawJSON", src)
+	}
+	return nil
+}
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if j == nil {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *RawJSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+// AnimalRepository reads animals.
+type AnimalRepository interface {
+	// List returns the animals matching q.
+	List(ctx context.Context, q AnimalQuery) ([]Animal, error)
+	// Get returns one animal, or ErrNotFound. An empty shelterID looks in
+	// every shelter, returning ErrAmbiguous if more than one has the id.
+	Get(ctx context.Context, shelterID, id string, adoptableOnly bool) (Animal, error)
+	// Stream calls fn with each animal matching q as it is read, without
+	// holding the result in memory. An error from fn stops the stream and
+	// is returned.
+	Stream(ctx context.Context, q AnimalQuery, fn func(Animal) error) error
+}
+
+// IntakeRepository reads intakes.
+type IntakeRepository interface {
+	// List returns the intakes matching q.
+	List(ctx context.Context, q IntakeQuery) ([]Intake, error)
+	// Stats summarises the intakes matching q, ignoring its paging.
+	Stats(ctx context.Context, q IntakeQuery) (IntakeStats, error)
+}
+
+// HistoryRepository reads the audit trail kept by the history triggers.
+type HistoryRepository interface {
+	// AnimalHistory returns the changes to an animal and its intakes,
+	// oldest first.
+	AnimalHistory(ctx context.Context, key AnimalKey) ([]Change, error)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/cache"
+	"github.com/jmoiron/sqlx"
+)
+
+// cachedResponse is an encoded JSON body and its ETag.
+type cachedResponse struct {
+	body []byte
+	etag string
+}
+
+func newCachedResponse(v interface{}) (cachedResponse, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return cachedResponse{}, err
+	}
+	body = append(body, '\n')
+	sum := sha256.Sum256(body)
+	return cachedResponse{body: body, etag: `"` + hex.EncodeToString(sum[:16]) + `"`}, nil
+}
+
+// ResponseCache holds encoded query results in an LRU and empties it
+// whenever the ETL finishes a load. A nil *ResponseCache caches nothing.
+type ResponseCache struct {
+	DB     *sqlx.DB
+	MaxAge time.Duration
+
+	lru      *cache.LRU[string, cachedResponse]
+	lastLoad atomic.Int64
+}
+
+// NewResponseCache returns a cache of size entries, or nil if size is 0.
+func NewResponseCache(db *sqlx.DB, size int, maxAge time.Duration) *ResponseCache {
+	if size == 0 {
+		return nil
+	}
+	return &ResponseCache{
+		DB:     db,
+		MaxAge: maxAge,
+		lru:    cache.NewLRU[string, cachedResponse](size),
+	}
+}
+
+// Key builds a cache key from the route, the caller's visibility and the
+// parsed params, so equivalent query strings share an entry.
+func (c *ResponseCache) Key(req *http.Request, params interface{}) string {
+	return fmt.Sprintf("%s|%t|%+v", routeName(req), auth.FromContext(req.Context()).CanSeeOperationalData(), params)
+}
+
+func (c *ResponseCache) Get(key string) (cachedResponse, bool) {
+	if c == nil {
+		return cachedResponse{}, false
+	}
+	return c.lru.Get(key)
+}
+
+func (c *ResponseCache) Add(key string, resp cachedResponse) {
+	if c == nil {
+		return
+	}
+	c.lru.Add(key, resp)
+}
+
+// Watch polls etl_runs every interval and purges the cache when a new load
+// has finished.
+func (c *ResponseCache) Watch(ctx context.Context, interval time.Duration) {
+	if c == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.checkLoads(ctx)
+			}
+		}
+	}()
+}
+
+func (c *ResponseCache) checkLoads(ctx context.Context) {
+	var latest int64
+	err := c.DB.GetContext(ctx, &latest, "SELECT COALESCE(MAX(id), 0) FROM etl_runs WHERE finished_at IS NOT NULL")
+	if err != nil {
+		slog.Warn("failed to check for new etl loads", "error", err)
+		return
+	}
+	if previous := c.lastLoad.Swap(latest); previous != latest {
+		slog.Info("etl load finished, purging response cache", "etl_run", latest, "entries", c.lru.Len())
+		c.lru.Purge()
+	}
+}
+
+// writeCached sends resp with its ETag and Cache-Control headers, or a 304
+// when the client already holds it. Responses that depend on the caller's
+// credentials are marked private.
+func (c *ResponseCache) writeCached(w http.ResponseWriter, req *http.Request, resp cachedResponse) {
+	maxAge := time.Duration(0)
+	if c != nil {
+		maxAge = c.MaxAge
+	}
+
+	h := w.Header()
+	h.Set("ETag", resp.etag)
+	h.Add("Vary", "Accept, Authorization, X-API-Key")
+	if auth.FromContext(req.Context()) == auth.Anonymous {
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	} else {
+		h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	}
+
+	if etagMatches(req.Header.Get("If-None-Match"), resp.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp.body)
+}
+
+// etagMatches implements the weak comparison If-None-Match calls for.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bbrombacher/animals/auth"
+	"github.com/bbrombacher/animals/repository"
+	"github.com/bbrombacher/animals/testdb"
+	"go.uber.org/mock/gomock"
+)
+
+var staff = auth.Principal{Subject: "s", Role: auth.RoleStaff}
+
+func TestResponseCacheKeys(t *testing.T) {
+	controller, animals := newTestController(t)
+	controller.Cache = NewResponseCache(nil, 10, time.Minute)
+	// a held animal only staff may see
+	held := repository.Animal{ShelterID: "sonoma", ID: "A2", AnimalName: "Mochi", AnimalType: "CAT"}
+	animals.EXPECT().List(gomock.Any(), repository.AnimalQuery{ShelterID: "sonoma", AdoptableOnly: true, Limit: 6}).
+		Return([]repository.Animal{biscuit}, nil)
+	animals.EXPECT().List(gomock.Any(), repository.AnimalQuery{ShelterID: "sonoma", Limit: 6}).
+		Return([]repository.Animal{biscuit, held}, nil)
+
+	get := func(query string, principal auth.Principal) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/v1/go-animals?"+query, nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		rec := httptest.NewRecorder()
+		controller.GetAnimals(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200: %s", rec.Code, rec.Body)
+		}
+		animalsJSON, _ := decodeBody(t, rec)["animals"].([]interface{})
+		return len(animalsJSON)
+	}
+
+	if n := get("limit=5&shelter=sonoma", auth.Anonymous); n != 1 {
+		t.Errorf("public caller got %d animals, want 1", n)
+	}
+	// the same params in another order, with one the handler ignores, hit
+	// the entry the mock's single call filled
+	if n := get("shelter=sonoma&colour=red&limit=5", auth.Anonymous); n != 1 {
+		t.Errorf("public caller got %d animals from the cache, want 1", n)
+	}
+	if n := get("limit=5&shelter=sonoma", staff); n != 2 {
+		t.Errorf("staff got %d animals, want 2 rather than the public entry", n)
+	}
+	if n := get("limit=5&shelter=sonoma", auth.Anonymous); n != 1 {
+		t.Errorf("public caller got %d animals after staff, want 1", n)
+	}
+}
+
+func TestWriteCached(t *testing.T) {
+	c := NewResponseCache(nil, 10, 5*time.Minute)
+	resp, err := newCachedResponse(map[string]string{"id": "A1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name         string
+		principal    auth.Principal
+		ifNoneMatch  string
+		wantStatus   int
+		cacheControl string
+	}{
+		{name: "public", principal: auth.Anonymous, wantStatus: http.StatusOK, cacheControl: "public, max-age=300"},
+		{name: "credentials", principal: staff, wantStatus: http.StatusOK, cacheControl: "private, max-age=300"},
+		{name: "public api key", principal: auth.Principal{Subject: "k", Role: auth.RolePublic}, wantStatus: http.StatusOK, cacheControl: "private, max-age=300"},
+		{name: "matching etag", principal: auth.Anonymous, ifNoneMatch: resp.etag, wantStatus: http.StatusNotModified, cacheControl: "public, max-age=300"},
+		{name: "weak etag in a list", principal: staff, ifNoneMatch: `"other", W/` + resp.etag, wantStatus: http.StatusNotModified, cacheControl: "private, max-age=300"},
+		{name: "any etag", principal: auth.Anonymous, ifNoneMatch: "*", wantStatus: http.StatusNotModified, cacheControl: "public, max-age=300"},
+		{name: "stale etag", principal: auth.Anonymous, ifNoneMatch: `"other"`, wantStatus: http.StatusOK, cacheControl: "public, max-age=300"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/go-animals", nil)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			c.writeCached(rec, req, resp)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tc.cacheControl {
+				t.Errorf("got Cache-Control %q, want %q", got, tc.cacheControl)
+			}
+			if got := rec.Header().Get("ETag"); got != resp.etag {
+				t.Errorf("got ETag %q, want %q", got, resp.etag)
+			}
+			wantBody := string(resp.body)
+			if tc.wantStatus == http.StatusNotModified {
+				wantBody = ""
+			}
+			if rec.Body.String() != wantBody {
+				t.Errorf("got body %q, want %q", rec.Body, wantBody)
+			}
+		})
+	}
+}
+
+func TestResponseCacheWatch(t *testing.T) {
+	db := testdb.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewResponseCache(db.DB, 10, time.Minute)
+	c.Add("key", cachedResponse{body: []byte("{}\n"), etag: `"e"`})
+	c.Watch(ctx, 10*time.Millisecond)
+
+	// an unfinished load leaves the cache alone
+	var runID int64
+	if err := db.Get(&runID, "INSERT INTO etl_runs (source) VALUES ('test') RETURNING id"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := c.Get("key"); !ok {
+		t.Fatal("the cache was purged before the load finished")
+	}
+
+	if _, err := db.Exec("UPDATE etl_runs SET finished_at = NOW() WHERE id = $1", runID); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := c.Get("key"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the cache wasn't purged after the load finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
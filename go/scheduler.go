@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bbrombacher/animals/config"
+	"github.com/bbrombacher/animals/ingest"
+	"github.com/jmoiron/sqlx"
+	"github.com/robfig/cron/v3"
+)
+
+// Statuses of a scheduled load.
+const (
+	IngestSucceeded   = "succeeded"
+	IngestFailed      = "failed"
+	IngestNotModified = "not_modified"
+	// IngestLocked means another load held ingest.LockKey and this one
+	// didn't run.
+	IngestLocked = "locked"
+)
+
+// IngestRun is the outcome of one scheduled load.
+type IngestRun struct {
+	Status          string
+	StartedAt       time.Time
+	DurationSeconds float64
+	Error           string
+	// ETLRun is the load's etl_runs id, 0 when it didn't start one.
+	ETLRun  int64
+	Stats   ingest.Stats
+	Changes ingest.Changes
+}
+
+// IngestStatus is what GET /v1/admin/ingest reports. Schedule is empty when
+// the scheduler is disabled.
+type IngestStatus struct {
+	Schedule string
+	Running  bool
+	NextRun  *time.Time
+	LastRun  *IngestRun
+}
+
+// IngestScheduler runs the ETL inside the server on a cron schedule. A nil
+// scheduler is disabled.
+type IngestScheduler struct {
+	spec     string
+	schedule cron.Schedule
+	// load runs one load, swapped out by tests.
+	load func(ctx context.Context) IngestRun
+
+	mu     sync.Mutex
+	status IngestStatus
+
+	// cancel and done stop the goroutine Start runs.
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewIngestScheduler returns a scheduler loading into db as cfg.ETL
+// describes, or nil when cfg.ETL.Schedule is empty.
+func NewIngestScheduler(db *sqlx.DB, cfg config.Config) (*IngestScheduler, error) {
+	if cfg.ETL.Schedule == "" {
+		return nil, nil
+	}
+	schedule, err := cron.ParseStandard(cfg.ETL.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	// fail at startup rather than at the first load
+	if _, err := ingest.NewJob(cfg.ETL, cfg.CSVPath); err != nil {
+		return nil, err
+	}
+	s := &IngestScheduler{spec: cfg.ETL.Schedule, schedule: schedule}
+	s.load = func(ctx context.Context) IngestRun {
+		return loadLocked(ctx, db, cfg)
+	}
+	return s, nil
+}
+
+// Start runs loads on the schedule until ctx is done or Stop is called.
+func (s *IngestScheduler) Start(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		for {
+			next := s.schedule.Next(time.Now())
+			s.mu.Lock()
+			s.status.NextRun = &next
+			s.mu.Unlock()
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				s.runOnce(ctx)
+			}
+		}
+	}()
+}
+
+// Stop cancels the load in progress, if any, and waits for the scheduler
+// to finish, so the DB pool can be closed after it.
+func (s *IngestScheduler) Stop() {
+	if s == nil || s.done == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// runOnce runs a load and records its outcome.
+func (s *IngestScheduler) runOnce(ctx context.Context) {
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	run := s.load(ctx)
+	observeIngestRun(run)
+	attrs := []any{"status", run.Status, "duration", run.DurationSeconds, "etl_run", run.ETLRun,
+		"rows", run.Stats.Read, "loaded", run.Stats.Loaded, "skipped", run.Stats.Skipped}
+	if run.Status == IngestFailed {
+		slog.ErrorContext(ctx, "scheduled etl run failed", append(attrs, "error", run.Error)...)
+	} else {
+		slog.InfoContext(ctx, "scheduled etl run finished", attrs...)
+	}
+
+	s.mu.Lock()
+	s.status.Running = false
+	s.status.LastRun = &run
+	s.mu.Unlock()
+}
+
+// Status returns the schedule, the next load and the last one.
+func (s *IngestScheduler) Status() IngestStatus {
+	if s == nil {
+		return IngestStatus{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Schedule = s.spec
+	return status
+}
+
+// GetStatus serves Status as JSON.
+func (s *IngestScheduler) GetStatus(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.Status())
+}
+
+// loadLocked runs the ETL cfg describes into db, unless another load, from
+// another instance or cmd/etl, holds ingest.LockKey. The sink takes the lock
+// in its load's transaction, so it goes with the transaction if the server
+// dies mid-load.
+func loadLocked(ctx context.Context, db *sqlx.DB, cfg config.Config) IngestRun {
+	run := IngestRun{StartedAt: time.Now()}
+	err := func() error {
+		job, err := ingest.NewJob(cfg.ETL, cfg.CSVPath)
+		if err != nil {
+			return err
+		}
+		pg := &ingest.Postgres{DB: db, Shelter: job.Profile.Shelter, Watermark: cfg.ETL.Watermark, SoftDelete: cfg.ETL.SoftDelete}
+		defer pg.Close()
+		job.Sink = pg
+
+		res, err := job.Run(ctx)
+		if errors.Is(err, ingest.ErrLocked) {
+			run.Status = IngestLocked
+			return nil
+		}
+		run.ETLRun, run.Stats, run.Changes = pg.RunID(), res.Stats, pg.Changes()
+		if err != nil {
+			return err
+		}
+		run.Status = IngestSucceeded
+		if res.NotModified {
+			run.Status = IngestNotModified
+		}
+		return nil
+	}()
+	if err != nil {
+		run.Status, run.Error = IngestFailed, err.Error()
+	}
+	run.DurationSeconds = time.Since(run.StartedAt).Seconds()
+	return run
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bbrombacher/animals/config"
+	"github.com/bbrombacher/animals/ingest"
+)
+
+// soon is a schedule firing a moment from now, every time.
+type soon struct{}
+
+func (soon) Next(t time.Time) time.Time {
+	return t.Add(time.Millisecond)
+}
+
+func TestIngestSchedulerRuns(t *testing.T) {
+	runs := make(chan IngestRun, 1)
+	s := &IngestScheduler{spec: "@soon", schedule: soon{}}
+	s.load = func(ctx context.Context) IngestRun {
+		run := IngestRun{Status: IngestSucceeded, StartedAt: time.Now(), ETLRun: 7, Stats: ingest.Stats{Read: 3, Loaded: 2, Skipped: 1}}
+		select {
+		case runs <- run:
+		default:
+		}
+		return run
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no load ran")
+	}
+	// the status is set once the load returns
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Status().LastRun == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	status := s.Status()
+	if status.Schedule != "@soon" || status.NextRun == nil {
+		t.Errorf("got status %+v", status)
+	}
+	if run := status.LastRun; run == nil || run.Status != IngestSucceeded || run.ETLRun != 7 || run.Stats.Loaded != 2 {
+		t.Errorf("got last run %+v", run)
+	}
+}
+
+func TestIngestSchedulerStop(t *testing.T) {
+	started := make(chan struct{})
+	var cancelled bool
+	s := &IngestScheduler{spec: "@soon", schedule: soon{}}
+	s.load = func(ctx context.Context) IngestRun {
+		close(started)
+		// a load only ends once it is cancelled
+		<-ctx.Done()
+		cancelled = true
+		return IngestRun{Status: IngestFailed, Error: ctx.Err().Error()}
+	}
+
+	s.Start(context.Background())
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no load ran")
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't return")
+	}
+	if !cancelled {
+		t.Error("Stop returned before the load did")
+	}
+	if run := s.Status().LastRun; run == nil || run.Status != IngestFailed {
+		t.Errorf("got last run %+v", run)
+	}
+
+	// a scheduler that never started, or is disabled, stops at once
+	(&IngestScheduler{}).Stop()
+	(*IngestScheduler)(nil).Stop()
+}
+
+func TestIngestSchedulerStatus(t *testing.T) {
+	s := &IngestScheduler{spec: "0 4 * * *"}
+	s.load = func(ctx context.Context) IngestRun {
+		return IngestRun{Status: IngestFailed, Error: "no such file"}
+	}
+	s.runOnce(context.Background())
+
+	rec := httptest.NewRecorder()
+	s.GetStatus(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/ingest", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	got := IngestStatus{}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Schedule != "0 4 * * *" || got.Running || got.LastRun == nil || got.LastRun.Status != IngestFailed || got.LastRun.Error != "no such file" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestIngestSchedulerDisabled(t *testing.T) {
+	s, err := NewIngestScheduler(nil, config.Config{})
+	if err != nil || s != nil {
+		t.Fatalf("got %v, %v for no schedule", s, err)
+	}
+	// a nil scheduler reports itself disabled
+	rec := httptest.NewRecorder()
+	s.GetStatus(rec, httptest.NewRequest(http.MethodGet, "/v1/admin/ingest", nil))
+	got := IngestStatus{}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Schedule != "" || got.LastRun != nil {
+		t.Errorf("got %+v", got)
+	}
+
+	cfg := config.Config{ETL: config.ETL{Schedule: "0 4 * * *", Profile: "atlantis"}}
+	if _, err := NewIngestScheduler(nil, cfg); err == nil {
+		t.Error("got no error for an unknown profile")
+	}
+}
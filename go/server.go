@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bbrombacher/animals/config"
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+)
+
+// newServer returns an http.Server with timeouts set so slow or idle
+// clients can't hold connections (and pooled DB conns) open indefinitely.
+func newServer(cfg config.HTTP, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// run serves until ctx is cancelled. It then calls onDrain and waits
+// DrainDelay so load balancers see /readyz fail, stops accepting
+// connections, drains in-flight requests for up to ShutdownTimeout and only
+// then closes the DB pool so no request loses its connection mid-query.
+// grpcSrv, if not nil, is served on grpcAddr and drained alongside srv.
+// scheduler is stopped before the pool closes, cancelling a load in progress.
+func run(ctx context.Context, srv *http.Server, grpcSrv *grpc.Server, grpcAddr string, db *sqlx.DB, scheduler *IngestScheduler, cfg config.HTTP, onDrain func()) error {
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("listening", "addr", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+	servers := 1
+	if grpcSrv != nil {
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			srv.Close()
+			scheduler.Stop()
+			db.Close()
+			return fmt.Errorf("failed to listen for grpc: %w", err)
+		}
+		go func() {
+			slog.Info("listening", "addr", grpcAddr, "protocol", "grpc")
+			serveErr <- grpcSrv.Serve(lis)
+		}()
+		servers++
+	}
+
+	select {
+	case err := <-serveErr:
+		srv.Close()
+		if grpcSrv != nil {
+			grpcSrv.Stop()
+		}
+		scheduler.Stop()
+		db.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutdown signal received, draining requests")
+	onDrain()
+	time.Sleep(cfg.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	grpcErr := make(chan error, 1)
+	if grpcSrv != nil {
+		go func() { grpcErr <- stopGRPC(shutdownCtx, grpcSrv) }()
+	} else {
+		grpcErr <- nil
+	}
+	shutdownErr := srv.Shutdown(shutdownCtx)
+	if err := <-grpcErr; shutdownErr == nil {
+		shutdownErr = err
+	}
+	for i := 0; i < servers; i++ {
+		if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server error during shutdown", "error", err)
+		}
+	}
+
+	scheduler.Stop()
+	if err := db.Close(); err != nil {
+		slog.Error("error closing db", "error", err)
+	}
+
+	if shutdownErr != nil {
+		return fmt.Errorf("failed to drain requests: %w", shutdownErr)
+	}
+	slog.Info("shutdown complete")
+	return nil
+}
+
+// stopGRPC waits for in-flight calls to finish, cancelling any still
+// running (long exports, say) once ctx is done.
+func stopGRPC(ctx context.Context, srv *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bbrombacher/animals/config"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// freeAddr returns a loopback address nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// TestRunShutdownOrder checks that once shutdown starts /readyz fails while
+// the server still accepts requests, and that a request in flight finishes
+// before the DB pool closes.
+func TestRunShutdownOrder(t *testing.T) {
+	// opening doesn't connect, and a closed pool fails pings without trying
+	db, err := sqlx.Open("postgres", "postgres://pguser@127.0.0.1:1/shelters?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	health := Health{DB: fakeDB{migration: schemaMigration{Version: 1}}, PingTimeout: time.Second, expectedVersion: 1, draining: &atomic.Bool{}}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	poolClosed := make(chan bool, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", health.Ready)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+		err := db.PingContext(context.Background())
+		poolClosed <- err != nil && strings.Contains(err.Error(), "database is closed")
+		io.WriteString(w, "done")
+	})
+
+	cfg := config.HTTP{Addr: freeAddr(t), DrainDelay: 300 * time.Millisecond, ShutdownTimeout: 5 * time.Second}
+	srv := newServer(cfg, mux)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- run(ctx, srv, nil, "", db, nil, cfg, health.SetDraining) }()
+
+	base := "http://" + cfg.Addr
+	// a spare keep-alive connection that never sends a request would hold
+	// Shutdown up, as the server can't tell it from a slow client
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	readyz := func() int {
+		resp, err := client.Get(base + "/readyz")
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for readyz() != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("the server never became ready")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := client.Get(base + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-started
+
+	cancel()
+	// during the drain delay the server still answers, but not ready
+	deadline = time.Now().Add(cfg.DrainDelay)
+	for {
+		if status := readyz(); status == http.StatusServiceUnavailable {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("/readyz answered %d during the drain delay, want 503", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// past the delay the server waits on the request in flight
+	time.Sleep(2 * cfg.DrainDelay)
+	select {
+	case err := <-runErr:
+		t.Fatalf("run returned %v with a request in flight", err)
+	default:
+	}
+	close(release)
+
+	if body := <-slow; body != "done" {
+		t.Errorf("the request in flight got %q, want done", body)
+	}
+	if <-poolClosed {
+		t.Error("the DB pool closed before the request in flight finished")
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("run: %v", err)
+	}
+	if err := db.Ping(); err == nil || !strings.Contains(err.Error(), "database is closed") {
+		t.Errorf("got ping error %v after shutdown, want the pool closed", err)
+	}
+	if _, err := client.Get(base + "/readyz"); err == nil {
+		t.Error("the server answered after shutdown")
+	}
+}
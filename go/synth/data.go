@@ -0,0 +1,84 @@
+package synth
+
+// The values below are drawn from the Sonoma export, weighted roughly as
+// they occur there.
+
+var animalTypes = []weighted{
+	{value: "DOG", weight: 55, subtypes: []string{
+		"PIT BULL", "CHIHUAHUA SH", "LABRADOR RETR", "GERM SHEPHERD", "AUST CATTLE DOG",
+		"BOXER", "BEAGLE", "SIBERIAN HUSKY", "DACHSHUND", "LABRADOR RETR/MIX", "PIT BULL/MIX",
+	}},
+	{value: "CAT", weight: 35, subtypes: []string{
+		"DOMESTIC SH", "DOMESTIC SH", "DOMESTIC SH", "DOMESTIC MH", "DOMESTIC LH", "SIAMESE", "MAINE COON",
+	}},
+	{value: "OTHER", weight: 10, subtypes: []string{
+		"RABBIT SH", "LOP-HOLLAND", "GUINEA PIG", "CHICKEN", "RAT", "GOAT",
+	}},
+}
+
+var kennelPrefix = map[string]string{
+	"DOG":   "DS",
+	"CAT":   "CS",
+	"OTHER": "OS",
+}
+
+var sizes = map[string][]string{
+	"DOG":   {"SMALL", "MED", "MED", "LARGE", "X-LRG", "PUPPY", "TOY"},
+	"CAT":   {"SMALL", "MED", "KITTN"},
+	"OTHER": {"SMALL", "TOY", "MED"},
+}
+
+var names = []string{
+	"BELLA", "LUNA", "MAX", "CHARLIE", "LUCY", "COOPER", "DAISY", "ROCKY", "BUDDY", "MOCHI",
+	"BISCUIT", "PEPPER", "SHADOW", "GINGER", "OREO", "BEAR", "MILO", "ZEUS", "ROSIE", "TIGER",
+}
+
+var colors = []string{
+	"BLACK", "WHITE", "BROWN", "TAN", "GRAY", "BRINDLE", "ORANGE TABBY", "BROWN TABBY", "CALICO", "TRICOLOR",
+}
+
+var sexes = []string{"Male", "Female", "Neutered", "Spayed"}
+
+var intakeTypes = []weighted{
+	{value: "STRAY", weight: 50, subtypes: []string{"FIELD", "OVER THE COUNTER", "PHONE"}},
+	{value: "OWNER SURRENDER", weight: 20, subtypes: []string{"OVER THE COUNTER", "FIELD"}},
+	{value: "CONFISCATE", weight: 10, subtypes: []string{"FIELD"}},
+	{value: "QUARANTINE", weight: 10, subtypes: []string{"FIELD", "OVER THE COUNTER"}},
+	{value: "TRANSFER", weight: 5, subtypes: []string{"MUNI SHELTER"}},
+	{value: "ADOPTION RETURN", weight: 5, subtypes: []string{"OVER THE COUNTER"}},
+}
+
+var outcomeTypes = []weighted{
+	{value: "RETURN TO OWNER", weight: 35, subtypes: []string{"OVER THE COUNTER", "FIELD"}},
+	{value: "ADOPTION", weight: 35, subtypes: []string{"INTERNET", "WALKIN", "SCAS WEB"}},
+	{value: "TRANSFER", weight: 15, subtypes: []string{"PARTNER", "MUNI SHELTER"}},
+	{value: "EUTHANIZE", weight: 10, subtypes: []string{"MEDICAL", "BEHAVIOR"}},
+	{value: "DIED", weight: 3, subtypes: []string{"IN KENNEL"}},
+	{value: "ESCAPED", weight: 2, subtypes: []string{"IN KENNEL"}},
+}
+
+var conditions = []string{"HEALTHY", "TREATABLE/REHAB", "TREATABLE/MANAGEABLE", "UNTREATABLE"}
+
+// place is where an animal came from: its jurisdiction and zip code.
+type place struct {
+	jurisdiction string
+	zip          string
+	lat, long    float64
+}
+
+var places = []place{
+	{"SANTA ROSA", "95401", 38.44, -122.76},
+	{"SANTA ROSA", "95403", 38.49, -122.75},
+	{"SANTA ROSA", "95404", 38.46, -122.69},
+	{"SANTA ROSA", "95407", 38.40, -122.74},
+	{"COUNTY", "95409", 38.46, -122.61},
+	{"COUNTY", "95439", 38.49, -122.78},
+	{"PETALUMA", "94952", 38.24, -122.65},
+	{"PETALUMA", "94954", 38.25, -122.61},
+	{"SEBASTOPOL", "95472", 38.40, -122.82},
+	{"WINDSOR", "95492", 38.54, -122.80},
+	{"HEALDSBURG", "95448", 38.61, -122.87},
+	{"SONOMA", "95476", 38.29, -122.46},
+	{"ROHNERT PARK", "94928", 38.35, -122.70},
+	{"CLOVERDALE", "95425", 38.80, -123.02},
+}
@@ -0,0 +1,299 @@
+// Package synth generates synthetic shelter data in the layout of the
+// Sonoma County export that cmd/etl loads (see rawdata/header_breakdown.txt).
+// Output is deterministic for a given seed, so it can back golden files and
+// reproducible load test databases.
+package synth
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+// Header is the export's header row. The first column is an unnamed row
+// index.
+var Header = []string{
+	"", "animal_name", "animal_type", "breed", "color", "sex", "animal_size", "date_of_birth",
+	"impound_number", "kennel_number", "animal_id", "intake_date", "outcome_date", "days_in_shelter",
+	"intake_type", "intake_subtype", "outcome_type", "outcome_subtype", "intake_condition", "outcome_condition",
+	"intake_jurisdiction", "outcome_jurisdiction", "zip_code", "location", "count",
+}
+
+// column indexes into Header
+const (
+	colIndex = iota
+	colAnimalName
+	colAnimalType
+	colBreed
+	colColor
+	colSex
+	colAnimalSize
+	colDateOfBirth
+	colImpoundNumber
+	colKennelNumber
+	colAnimalID
+	colIntakeDate
+	colOutcomeDate
+	colDaysInShelter
+	colIntakeType
+	colIntakeSubtype
+	colOutcomeType
+	colOutcomeSubtype
+	colIntakeCondition
+	colOutcomeCondition
+	colIntakeJurisdiction
+	colOutcomeJurisdiction
+	colZipCode
+	colLocation
+	colCount
+)
+
+// dateLayout is how the export writes dates.
+const dateLayout = "01/02/2006"
+
+// Options controls what Generate writes.
+type Options struct {
+	// Rows is the number of data rows, not counting the header.
+	Rows int
+	Seed uint64
+	// RepeatRate is the chance a row is another intake of an animal that
+	// was already written.
+	RepeatRate float64
+	// MissingRate is the chance each optional value is empty or "unknown".
+	MissingRate float64
+	// MalformedRate is the chance a row is broken in a way the ETL must
+	// reject: too few columns, or a count that isn't a number.
+	MalformedRate float64
+	// End is the export date; intakes fall in the ten years before it.
+	// Zero means the start of 2025.
+	End time.Time
+}
+
+// Stats counts what Generate wrote.
+type Stats struct {
+	Rows      int
+	Malformed int
+	// Animals and Intakes are what a correct load of the well-formed rows
+	// produces.
+	Animals int
+	Intakes int
+}
+
+// Generate writes the header and opts.Rows rows of CSV to w.
+func Generate(w io.Writer, opts Options) (Stats, error) {
+	g := generator{
+		r:    rand.New(rand.NewPCG(opts.Seed, 0)),
+		opts: opts,
+		end:  opts.End,
+	}
+	if g.end.IsZero() {
+		g.end = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Header); err != nil {
+		return Stats{}, err
+	}
+	stats := Stats{}
+	loaded := map[string]bool{}
+	for i := 0; i < opts.Rows; i++ {
+		row, ok := g.row(i)
+		if err := cw.Write(row); err != nil {
+			return stats, err
+		}
+		stats.Rows++
+		if !ok {
+			stats.Malformed++
+			continue
+		}
+		stats.Intakes++
+		loaded[row[colAnimalID]] = true
+	}
+	stats.Animals = len(loaded)
+	cw.Flush()
+	return stats, cw.Error()
+}
+
+type animal struct {
+	id, name, animalType, breed, color, sex, size, dateOfBirth string
+	// born is set even when dateOfBirth is missing, so intakes follow it.
+	born time.Time
+}
+
+type generator struct {
+	r       *rand.Rand
+	opts    Options
+	end     time.Time
+	animals []animal
+	impound int
+}
+
+// row returns the i'th row and whether it is well-formed.
+func (g *generator) row(i int) ([]string, bool) {
+	a := g.animal()
+	intakeType := pickWeighted(g.r, intakeTypes)
+	intakeDate, outcomeDate, days := g.stay(a.born)
+	place := places[g.r.IntN(len(places))]
+	g.impound++
+
+	row := make([]string, len(Header))
+	row[colIndex] = strconv.Itoa(i)
+	row[colAnimalName] = a.name
+	row[colAnimalType] = a.animalType
+	row[colBreed] = a.breed
+	row[colColor] = a.color
+	row[colSex] = a.sex
+	row[colAnimalSize] = a.size
+	row[colDateOfBirth] = a.dateOfBirth
+	row[colImpoundNumber] = fmt.Sprintf("K%02d-%06d", intakeDate.Year()%100, g.impound)
+	row[colKennelNumber] = fmt.Sprintf("%s%02d", kennelPrefix[a.animalType], g.r.IntN(40)+1)
+	row[colAnimalID] = a.id
+	row[colIntakeDate] = intakeDate.Format(dateLayout)
+	row[colDaysInShelter] = thousands(days)
+	row[colIntakeType] = intakeType.value
+	row[colIntakeSubtype] = g.missing(pick(g.r, intakeType.subtypes), "")
+	row[colIntakeCondition] = g.missing(pick(g.r, conditions), "UNKNOWN")
+	row[colIntakeJurisdiction] = g.missing(place.jurisdiction, "")
+	row[colCount] = "1"
+	if g.r.Float64() < 0.05 {
+		row[colCount] = strconv.Itoa(g.r.IntN(4) + 2)
+	}
+
+	if outcomeDate != nil {
+		outcomeType := pickWeighted(g.r, outcomeTypes)
+		row[colOutcomeDate] = outcomeDate.Format(dateLayout)
+		row[colOutcomeType] = outcomeType.value
+		row[colOutcomeSubtype] = g.missing(pick(g.r, outcomeType.subtypes), "")
+		row[colOutcomeCondition] = g.missing(pick(g.r, conditions), "UNKNOWN")
+		row[colOutcomeJurisdiction] = g.missing(place.jurisdiction, "")
+		if g.r.Float64() >= g.opts.MissingRate {
+			// the export mixes integer and float zip codes
+			row[colZipCode] = place.zip
+			if g.r.IntN(2) == 0 {
+				row[colZipCode] += ".0"
+			}
+			row[colLocation] = fmt.Sprintf("%s(%.6f, %.6f)", place.zip,
+				place.lat+g.r.Float64()*0.04-0.02, place.long+g.r.Float64()*0.04-0.02)
+		}
+	}
+
+	if g.r.Float64() < g.opts.MalformedRate {
+		return g.malform(row), false
+	}
+	return row, true
+}
+
+// animal returns an animal seen before, at opts.RepeatRate, or a new one.
+func (g *generator) animal() animal {
+	if len(g.animals) > 0 && g.r.Float64() < g.opts.RepeatRate {
+		return g.animals[g.r.IntN(len(g.animals))]
+	}
+
+	animalType := pickWeighted(g.r, animalTypes)
+	a := animal{
+		id:         fmt.Sprintf("A%06d", 300000+len(g.animals)),
+		name:       g.missing(pick(g.r, names), "unknown"),
+		animalType: animalType.value,
+		breed:      pick(g.r, animalType.subtypes),
+		color:      pick(g.r, colors),
+		sex:        g.missing(pick(g.r, sexes), "Unknown"),
+		size:       pick(g.r, sizes[animalType.value]),
+		born:       g.end.AddDate(0, 0, -g.r.IntN(15*365)-30),
+	}
+	if g.r.IntN(3) == 0 {
+		a.color += "/" + pick(g.r, colors)
+	}
+	if g.r.Float64() >= g.opts.MissingRate {
+		a.dateOfBirth = a.born.Format(dateLayout)
+	} else if g.r.IntN(2) == 0 {
+		a.dateOfBirth = "unknown"
+	}
+	g.animals = append(g.animals, a)
+	return a
+}
+
+// stay returns an intake date after born, the outcome date (nil while the
+// animal is still in the shelter) and the days between them.
+func (g *generator) stay(born time.Time) (time.Time, *time.Time, int) {
+	start := g.end.AddDate(-10, 0, 0)
+	if born.After(start) {
+		start = born
+	}
+	span := max(int(g.end.Sub(start).Hours()/24), 1)
+	if g.r.Float64() < 0.05 {
+		intake := g.end.AddDate(0, 0, -g.r.IntN(min(span, 60))-1)
+		return intake, nil, int(g.end.Sub(intake).Hours() / 24)
+	}
+
+	days := min(int(g.r.ExpFloat64()*20), 900)
+	if g.r.Float64() < 0.01 {
+		// long-term residents, whose days get a thousands separator
+		days = 1000 + g.r.IntN(800)
+	}
+	days = min(days, span-1)
+	intake := start.AddDate(0, 0, g.r.IntN(span-days))
+	outcome := intake.AddDate(0, 0, days)
+	return intake, &outcome, days
+}
+
+// missing returns value, or at opts.MissingRate either "" or unknown.
+func (g *generator) missing(value, unknown string) string {
+	if g.r.Float64() >= g.opts.MissingRate {
+		return value
+	}
+	if unknown != "" && g.r.IntN(2) == 0 {
+		return unknown
+	}
+	return ""
+}
+
+// malform breaks row in one of the ways real exports have been broken.
+func (g *generator) malform(row []string) []string {
+	switch g.r.IntN(3) {
+	case 0:
+		return row[:g.r.IntN(colCount)+1]
+	case 1:
+		row[colCount] = "n/a"
+	default:
+		row[colCount] = ""
+	}
+	return row
+}
+
+// thousands formats n the way the export does, e.g. 1,049.
+func thousands(n int) string {
+	s := strconv.Itoa(n)
+	if n < 1000 {
+		return s
+	}
+	return s[:len(s)-3] + "," + s[len(s)-3:]
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.IntN(len(values))]
+}
+
+// weighted is a value and, for types, its subtypes.
+type weighted struct {
+	value    string
+	weight   int
+	subtypes []string
+}
+
+func pickWeighted(r *rand.Rand, values []weighted) weighted {
+	total := 0
+	for _, v := range values {
+		total += v.weight
+	}
+	n := r.IntN(total)
+	for _, v := range values {
+		if n < v.weight {
+			return v
+		}
+		n -= v.weight
+	}
+	return values[len(values)-1]
+}
@@ -0,0 +1,105 @@
+package synth
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/golden.csv")
+
+// goldenOptions exercise every kind of row in a small file.
+var goldenOptions = Options{Rows: 60, Seed: 42, RepeatRate: 0.3, MissingRate: 0.1, MalformedRate: 0.1}
+
+// TestGolden pins the generator's output, so a change to it is a reviewed
+// change to testdata/golden.csv. Run with -update to accept one.
+func TestGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := Generate(&buf, goldenOptions); err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile("testdata/golden.csv", buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile("testdata/golden.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("output differs from testdata/golden.csv; run go test ./synth -update if that's intended")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	opts := Options{Rows: 5000, Seed: 7, RepeatRate: 0.25, MissingRate: 0.05, MalformedRate: 0.02}
+	var buf bytes.Buffer
+	stats, err := Generate(&buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := csv.NewReader(&buf)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != opts.Rows+1 || stats.Rows != opts.Rows {
+		t.Fatalf("got %d records and %d rows in stats, want %d rows and a header", len(records), stats.Rows, opts.Rows)
+	}
+
+	malformed, thousands, unknownBirth := 0, 0, 0
+	animals, intakes := map[string]bool{}, map[string]bool{}
+	for _, rec := range records[1:] {
+		if len(rec) != len(Header) {
+			malformed++
+			continue
+		}
+		if _, err := strconv.Atoi(rec[colCount]); err != nil {
+			malformed++
+			continue
+		}
+		if strings.Contains(rec[colDaysInShelter], ",") {
+			thousands++
+		}
+		if rec[colDateOfBirth] == "unknown" {
+			unknownBirth++
+		}
+		intake, err := time.Parse(dateLayout, rec[colIntakeDate])
+		if err != nil {
+			t.Fatalf("row %s: bad intake date: %v", rec[colIndex], err)
+		}
+		if rec[colOutcomeDate] != "" {
+			outcome, err := time.Parse(dateLayout, rec[colOutcomeDate])
+			if err != nil || outcome.Before(intake) {
+				t.Errorf("row %s: outcome %s isn't after intake %s", rec[colIndex], rec[colOutcomeDate], rec[colIntakeDate])
+			}
+		}
+		key := rec[colAnimalID] + "/" + rec[colKennelNumber] + "/" + rec[colImpoundNumber]
+		if intakes[key] {
+			t.Errorf("row %s: intake %s repeats", rec[colIndex], key)
+		}
+		intakes[key] = true
+		animals[rec[colAnimalID]] = true
+	}
+
+	if malformed != stats.Malformed || len(intakes) != stats.Intakes || len(animals) != stats.Animals {
+		t.Errorf("stats %+v don't match the file: %d malformed, %d intakes, %d animals", stats, malformed, len(intakes), len(animals))
+	}
+	if malformed < 50 || malformed > 150 {
+		t.Errorf("got %d malformed rows, want about %v", malformed, opts.MalformedRate*float64(opts.Rows))
+	}
+	if stats.Animals > stats.Intakes*85/100 {
+		t.Errorf("got %d animals for %d intakes, want repeat intakes", stats.Animals, stats.Intakes)
+	}
+	if thousands == 0 || unknownBirth == 0 {
+		t.Errorf("got %d long stays and %d unknown birth dates, want some of each", thousands, unknownBirth)
+	}
+}
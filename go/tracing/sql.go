@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Connx checks a connection out of db inside a span, recording how long the
+// caller waited on the pool.
+func Connx(ctx context.Context, db *sqlx.DB) (*sqlx.Conn, error) {
+	ctx, span := Tracer.Start(ctx, "db.pool.acquire", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	start := time.Now()
+	conn, err := db.Connx(ctx)
+	wait := time.Since(start)
+
+	stats := db.Stats()
+	span.SetAttributes(
+		attribute.Int64("db.pool.wait_ms", wait.Milliseconds()),
+		attribute.Int("db.pool.in_use", stats.InUse),
+		attribute.Int("db.pool.open", stats.OpenConnections),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return conn, err
+}
+
+// StartQuery starts a span for the named SQL statement. Finish it with
+// EndQuery once the rows are read.
+func StartQuery(ctx context.Context, name, statement string) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, "db.query "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+			attribute.String("db.query.text", statement),
+		),
+	)
+}
+
+// EndQuery records the rows returned and any error on span and ends it.
+func EndQuery(span trace.Span, rows int, err error) {
+	span.SetAttributes(attribute.Int("db.response.returned_rows", rows))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
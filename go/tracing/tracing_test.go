@@ -0,0 +1,33 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bbrombacher/animals/config"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{"none", "stdout", "otlp"} {
+		t.Run(exporter, func(t *testing.T) {
+			ctx := context.Background()
+			shutdown, err := Setup(ctx, config.Tracing{Exporter: exporter, OTLPEndpoint: "localhost:4318", SampleRatio: 0}, "animals-test")
+			if err != nil {
+				t.Fatalf("Setup: %v", err)
+			}
+			// nothing is sampled, so shutting down has nothing to send
+			_, span := otel.Tracer("test").Start(ctx, "test")
+			span.End()
+			if err := shutdown(ctx); err != nil {
+				t.Errorf("shutdown: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), config.Tracing{Exporter: "zipkin"}, "animals-test"); err == nil {
+		t.Error("Setup accepted an unknown exporter")
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS etl_runs (
+    id SERIAL PRIMARY KEY,
+    source TEXT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    rows_read INT NOT NULL DEFAULT 0
+);
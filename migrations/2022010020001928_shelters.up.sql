@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS shelters (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Every row loaded so far came from the Sonoma County export.
+INSERT INTO shelters (id, name) VALUES ('sonoma', 'Sonoma County Animal Services')
+    ON CONFLICT (id) DO NOTHING;
+
+-- Animal ids are only unique within a shelter, so the shelter joins every key.
+ALTER TABLE animal_intake DROP CONSTRAINT fk_animal_id;
+ALTER TABLE animal_intake DROP CONSTRAINT intake_pk;
+ALTER TABLE animals DROP CONSTRAINT animals_pkey;
+
+ALTER TABLE animals ADD COLUMN shelter_id TEXT NOT NULL DEFAULT 'sonoma' REFERENCES shelters(id);
+ALTER TABLE animal_intake ADD COLUMN shelter_id TEXT NOT NULL DEFAULT 'sonoma' REFERENCES shelters(id);
+ALTER TABLE animals ALTER COLUMN shelter_id DROP DEFAULT;
+ALTER TABLE animal_intake ALTER COLUMN shelter_id DROP DEFAULT;
+
+ALTER TABLE animals ADD PRIMARY KEY (shelter_id, id);
+ALTER TABLE animal_intake ADD CONSTRAINT intake_pk
+    PRIMARY KEY (shelter_id, animal_id, kennel_number, impound_number);
+ALTER TABLE animal_intake ADD CONSTRAINT fk_animal_id
+    FOREIGN KEY (shelter_id, animal_id) REFERENCES animals(shelter_id, id);
+
+-- Public lookups by id alone still need an index on it.
+CREATE INDEX IF NOT EXISTS animals_id_idx ON animals (id);
+
+ALTER TABLE etl_runs ADD COLUMN shelter_id TEXT REFERENCES shelters(id);
@@ -0,0 +1,84 @@
+-- Every insert, update and delete of an animal or intake is recorded by the
+-- triggers below, whatever makes it. Inserts and deletes keep the whole row;
+-- updates keep one row per changed field.
+CREATE TABLE IF NOT EXISTS animal_history (
+    id BIGSERIAL PRIMARY KEY,
+    shelter_id TEXT NOT NULL,
+    animal_id TEXT NOT NULL,
+    operation TEXT NOT NULL CHECK (operation IN ('INSERT', 'UPDATE', 'DELETE')),
+    field TEXT,
+    old_value JSONB,
+    new_value JSONB,
+    source TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS animal_history_animal_idx ON animal_history (shelter_id, animal_id, changed_at);
+
+CREATE TABLE IF NOT EXISTS intake_history (
+    id BIGSERIAL PRIMARY KEY,
+    shelter_id TEXT NOT NULL,
+    animal_id TEXT NOT NULL,
+    kennel_number TEXT NOT NULL,
+    impound_number TEXT NOT NULL,
+    operation TEXT NOT NULL CHECK (operation IN ('INSERT', 'UPDATE', 'DELETE')),
+    field TEXT,
+    old_value JSONB,
+    new_value JSONB,
+    source TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS intake_history_animal_idx ON intake_history (shelter_id, animal_id, changed_at);
+
+-- history_changes lists what an operation changed: every changed field of an
+-- update, or the whole row of an insert or delete.
+CREATE OR REPLACE FUNCTION history_changes(operation TEXT, old_row JSONB, new_row JSONB)
+RETURNS TABLE (field TEXT, old_value JSONB, new_value JSONB) AS $$
+    SELECT n.key, old_row -> n.key, n.value
+    FROM jsonb_each(new_row) n
+    WHERE operation = 'UPDATE' AND n.value IS DISTINCT FROM old_row -> n.key
+    UNION ALL
+    SELECT NULL, old_row, new_row
+    WHERE operation <> 'UPDATE'
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Writers name themselves in the animals.change_source setting, e.g.
+-- "etl_run:42" or "user:apikey:alice"; other changes are put down to the
+-- database user.
+CREATE OR REPLACE FUNCTION record_history() RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB;
+    new_row JSONB;
+    key_row JSONB;
+    changed_by TEXT := COALESCE(NULLIF(current_setting('animals.change_source', true), ''), 'db:' || current_user);
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW);
+    END IF;
+    key_row := COALESCE(new_row, old_row);
+
+    IF TG_TABLE_NAME = 'animals' THEN
+        INSERT INTO animal_history (shelter_id, animal_id, operation, field, old_value, new_value, source)
+        SELECT key_row ->> 'shelter_id', key_row ->> 'id', TG_OP, c.field, c.old_value, c.new_value, changed_by
+        FROM history_changes(TG_OP, old_row, new_row) c;
+    ELSE
+        INSERT INTO intake_history (shelter_id, animal_id, kennel_number, impound_number, operation, field, old_value, new_value, source)
+        SELECT key_row ->> 'shelter_id', key_row ->> 'animal_id', key_row ->> 'kennel_number', key_row ->> 'impound_number',
+            TG_OP, c.field, c.old_value, c.new_value, changed_by
+        FROM history_changes(TG_OP, old_row, new_row) c;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS animals_history ON animals;
+CREATE TRIGGER animals_history AFTER INSERT OR UPDATE OR DELETE ON animals
+    FOR EACH ROW EXECUTE FUNCTION record_history();
+
+DROP TRIGGER IF EXISTS animal_intake_history ON animal_intake;
+CREATE TRIGGER animal_intake_history AFTER INSERT OR UPDATE OR DELETE ON animal_intake
+    FOR EACH ROW EXECUTE FUNCTION record_history();
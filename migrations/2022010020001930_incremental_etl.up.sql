@@ -0,0 +1,27 @@
+-- The content hash of every source row from a shelter's last load, so the
+-- next load applies only rows that are new or changed.
+CREATE TABLE IF NOT EXISTS etl_row_hashes (
+    shelter_id TEXT NOT NULL REFERENCES shelters(id),
+    animal_id TEXT NOT NULL,
+    kennel_number TEXT NOT NULL,
+    impound_number TEXT NOT NULL,
+    hash BYTEA NOT NULL,
+    intake_date TIMESTAMPTZ,
+    etl_run_id INT NOT NULL REFERENCES etl_runs(id),
+
+    PRIMARY KEY (shelter_id, animal_id, kennel_number, impound_number)
+);
+
+ALTER TABLE etl_runs
+    ADD COLUMN IF NOT EXISTS rows_new INT,
+    ADD COLUMN IF NOT EXISTS rows_changed INT,
+    ADD COLUMN IF NOT EXISTS rows_unchanged INT,
+    ADD COLUMN IF NOT EXISTS rows_disappeared INT,
+    ADD COLUMN IF NOT EXISTS rows_before_watermark INT,
+    -- the latest intake_date the load saw
+    ADD COLUMN IF NOT EXISTS watermark TIMESTAMPTZ;
+
+-- Rows that vanished from the source are kept, but hidden, when the ETL
+-- runs with soft deletes.
+ALTER TABLE animals ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE animal_intake ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
@@ -0,0 +1,10 @@
+-- cmd/etl used to read the export's MM/DD/YYYY dates day first, so rows
+-- loaded before that was fixed hold the wrong dates: 01/13/2024 was stored
+-- as 2025-01-01. The stored dates can't be mapped back to the export's, so
+-- this forgets what earlier loads saw instead. The next load compares no
+-- row against a hash or watermark, writes every row again with its dates
+-- read month first, and the upserts correct the rows whose dates differ.
+-- That load soft deletes nothing, and rows no longer in the export keep
+-- their dates.
+DELETE FROM etl_row_hashes;
+UPDATE etl_runs SET watermark = NULL WHERE watermark IS NOT NULL;
@@ -0,0 +1,4 @@
+-- An ETL load writes a batch of intakes before the animals they reference,
+-- which it only resolves once it has seen every row, so it defers this
+-- check to its commit. Every other writer still gets it per statement.
+ALTER TABLE animal_intake ALTER CONSTRAINT fk_animal_id DEFERRABLE INITIALLY IMMEDIATE;